@@ -0,0 +1,103 @@
+package kami
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// AnyMethod is the method that Any registers handlers under.
+// Use it to change Any handlers with Update, Remove, and Replace.
+const AnyMethod = "*"
+
+// allowed returns the sorted methods allowed for a path, given its handlers in tree.
+func allowed(tree *httptreemux.TreeMux, methods map[string]httptreemux.HandlerFunc) []string {
+	allowed := make([]string, 0, len(methods)+2)
+	for method := range methods {
+		if method != AnyMethod {
+			allowed = append(allowed, method)
+		}
+	}
+	if _, ok := methods["GET"]; ok && tree.HeadCanUseGet {
+		if _, ok := methods["HEAD"]; !ok {
+			allowed = append(allowed, "HEAD")
+		}
+	}
+	if _, ok := methods["OPTIONS"]; !ok && tree.OptionsHandler != nil {
+		allowed = append(allowed, "OPTIONS")
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// allowedFor looks up the methods allowed for the path of r.
+func (t *routeTable) allowedFor(w http.ResponseWriter, r *http.Request) []string {
+	routes := t.current()
+	methods := make(map[string]httptreemux.HandlerFunc)
+	probe := *r
+	for _, method := range routes.methods {
+		if method == AnyMethod {
+			continue
+		}
+		probe.Method = method
+		if lr, _ := routes.tree.Lookup(w, &probe); lr.StatusCode == http.StatusOK {
+			methods[method] = nil
+		}
+	}
+	return allowed(routes.tree, methods)
+}
+
+// methodNotAllowed returns an httptreemux handler for 405s that sets the Allow header before calling h.
+// If enabled is false, notFound is called instead.
+func (t *routeTable) methodNotAllowed(h httptreemux.HandlerFunc, enabled *bool) func(http.ResponseWriter, *http.Request, map[string]httptreemux.HandlerFunc) {
+	return func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
+		if t.serveAny(w, r) {
+			return
+		}
+		tree := t.current().tree
+		if !*enabled {
+			tree.NotFoundHandler(w, r)
+			return
+		}
+		allow := allowed(tree, methods)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		h(w, withAllowedMethods(r, allow), nil)
+	}
+}
+
+// autoOptions returns an httptreemux handler for OPTIONS requests that sets the Allow header before calling h.
+func (t *routeTable) autoOptions(h httptreemux.HandlerFunc) httptreemux.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if t.serveAny(w, r) {
+			return
+		}
+		allow := t.allowedFor(w, r)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		h(w, withAllowedMethods(r, allow), params)
+	}
+}
+
+// serveAny serves r with the handler registered for AnyMethod, if the path has one.
+// httptreemux only knows about methods it has handlers for, so requests for other methods
+// end up as 405s or automatic OPTIONS responses, which check for Any handlers first.
+func (t *routeTable) serveAny(w http.ResponseWriter, r *http.Request) bool {
+	routes := t.current()
+	if !containsString(routes.methods, AnyMethod) {
+		return false
+	}
+	probe := *r
+	probe.Method = AnyMethod
+	lr, found := routes.tree.Lookup(w, &probe)
+	if !found {
+		return false
+	}
+	routes.tree.ServeLookupResult(w, r, lr)
+	return true
+}
+
+// noContent is the default handler for automatic OPTIONS responses.
+var noContent = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
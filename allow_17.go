@@ -0,0 +1,23 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"net/http"
+)
+
+type allowedMethodsKey struct{}
+
+// AllowedMethods returns the methods allowed for the requested path.
+// It is available to MethodNotAllowed handlers and automatic OPTIONS responses,
+// along with middleware that runs for them.
+func AllowedMethods(ctx context.Context) []string {
+	methods, _ := ctx.Value(allowedMethodsKey{}).([]string)
+	return methods
+}
+
+// withAllowedMethods passes the allowed methods along to kami.handle.
+func withAllowedMethods(r *http.Request, methods []string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), allowedMethodsKey{}, methods))
+}
@@ -0,0 +1,12 @@
+// +build !go1.7
+
+package kami
+
+import (
+	"net/http"
+)
+
+// withAllowedMethods does nothing, because requests can't carry a context before Go 1.7.
+func withAllowedMethods(r *http.Request, methods []string) *http.Request {
+	return r
+}
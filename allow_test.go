@@ -0,0 +1,146 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestAllowHeader(t *testing.T) {
+	mux := kami.New()
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	mux.Get("/users/:id", noop)
+	mux.Put("/users/:id", noop)
+	mux.Post("/users", noop)
+
+	req := httptest.NewRequest("DELETE", "/users/1", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Error("want 405, got", resp.Code)
+	}
+	if allow := resp.Header().Get("Allow"); allow != "GET, HEAD, PUT" {
+		t.Errorf("want Allow: GET, HEAD, PUT; got %q", allow)
+	}
+
+	var allowed []string
+	mux.MethodNotAllowed(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		allowed = kami.AllowedMethods(ctx)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	if strings.Join(allowed, ",") != "GET,HEAD,PUT" {
+		t.Errorf("want allowed methods GET,HEAD,PUT; got %v", allowed)
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	mux := kami.New()
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	mux.Get("/users/:id", noop)
+	mux.Delete("/users/:id", noop)
+	mux.Post("/users", noop)
+	mux.Options("/custom", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mux.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(kami.AllowedMethods(ctx), ", "))
+		}
+		return ctx
+	})
+
+	options := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("OPTIONS", path, nil))
+		return resp
+	}
+
+	if resp := options("/users/1"); resp.Code != http.StatusMethodNotAllowed {
+		t.Error("OPTIONS should be 405 before EnableAutoOptions, got", resp.Code)
+	}
+
+	mux.EnableAutoOptions(true)
+	resp := options("/users/1")
+	if resp.Code != http.StatusNoContent {
+		t.Error("want 204, got", resp.Code)
+	}
+	if allow := resp.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("want Allow: DELETE, GET, HEAD, OPTIONS; got %q", allow)
+	}
+	if cors := resp.Header().Get("Access-Control-Allow-Methods"); cors != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("middleware should see allowed methods, got %q", cors)
+	}
+	if resp := options("/custom"); resp.Code != http.StatusTeapot {
+		t.Error("explicit OPTIONS handler should win, got", resp.Code)
+	}
+	if resp := options("/nowhere"); resp.Code != http.StatusNotFound {
+		t.Error("want 404 for unknown path, got", resp.Code)
+	}
+
+	// 405s now advertise OPTIONS too
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("PATCH", "/users", nil))
+	if allow := resp.Header().Get("Allow"); allow != "OPTIONS, POST" {
+		t.Errorf("want Allow: OPTIONS, POST; got %q", allow)
+	}
+}
+
+func TestAny(t *testing.T) {
+	mux := kami.New()
+	mux.EnableAutoOptions(true)
+	handler := func(name string) kami.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", name)
+			w.Header().Set("X-Param", kami.Param(ctx, "path"))
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	mux.Any("/dav/*path", handler("any"))
+	mux.Get("/dav/readme", handler("get"))
+	mux.Any("/proxy", handler("proxy"))
+	mux.Post("/proxy", handler("post"))
+	mux.Get("/static", handler("static"))
+
+	expect := func(method, path string, code int, name string) {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest(method, path, nil))
+		if resp.Code != code {
+			t.Errorf("%s %s: want code %d, got %d", method, path, code, resp.Code)
+		}
+		if got := resp.Header().Get("X-Handler"); got != name {
+			t.Errorf("%s %s: want handler %q, got %q", method, path, name, got)
+		}
+	}
+	expect("PROPFIND", "/dav/a/b", 200, "any")
+	expect("GET", "/dav/readme", 200, "get")
+	expect("REPORT", "/dav/readme", 200, "any")
+	expect("OPTIONS", "/dav/readme", 200, "any")
+	expect("HEAD", "/dav/readme", 200, "get")
+	expect("HEAD", "/dav/a/b", 200, "any")
+	expect("HEAD", "/proxy", 200, "proxy")
+	expect("POST", "/proxy", 200, "post")
+	expect("PUT", "/proxy", 200, "proxy")
+	expect("GET", "/proxy", 200, "proxy")
+	expect("PROPFIND", "/static", 405, "")
+	expect("PROPFIND", "/missing", 404, "")
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("MKCOL", "/dav/a/b", nil))
+	if got := resp.Header().Get("X-Param"); got != "a/b" {
+		t.Errorf("want param a/b, got %q", got)
+	}
+
+	if !mux.Remove(kami.AnyMethod, "/proxy") {
+		t.Error("Any route wasn't removed")
+	}
+	expect("PUT", "/proxy", 405, "")
+}
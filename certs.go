@@ -0,0 +1,219 @@
+// +build !appengine
+
+package kami
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertSource loads TLS certificates from disk and reloads them when the files change,
+// so certificates can be rotated without restarting. Use its GetCertificate method
+// in the tls.Config given to ServeTLS, or use CertSource.TLSConfig.
+// When multiple certificates are added, one is chosen based on the client's SNI server name.
+// The first certificate added is used when no other certificate matches.
+type CertSource struct {
+	mu    sync.Mutex // guards pairs and stop
+	pairs []*certPair
+	stop  chan struct{}
+
+	current atomic.Value // *certSet
+}
+
+// certPair is a certificate/key pair on disk.
+type certPair struct {
+	certFile, keyFile string
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+	cert              *tls.Certificate
+}
+
+// certSet is an immutable snapshot of loaded certificates.
+type certSet struct {
+	certs []*tls.Certificate
+	names map[string]*tls.Certificate
+}
+
+// NewCertSource creates an empty CertSource. Add certificates to it with Add.
+func NewCertSource() *CertSource {
+	return new(CertSource)
+}
+
+// Add loads the given PEM encoded certificate and key files, watching them for changes.
+func (cs *CertSource) Add(certFile, keyFile string) error {
+	cf := &certPair{certFile: certFile, keyFile: keyFile}
+	if _, err := cf.load(); err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.pairs = append(cs.pairs, cf)
+	cs.swap()
+	return nil
+}
+
+// Reload checks every certificate's files and reloads the ones that changed.
+// If a certificate fails to load, the previous version is kept and an error is returned.
+func (cs *CertSource) Reload() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var errs []string
+	changed := false
+	for _, cf := range cs.pairs {
+		ok, err := cf.load()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		changed = changed || ok
+	}
+	if changed {
+		cs.swap()
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Watch starts checking for changed certificates every interval in the background.
+// Errors are logged and the previous certificates stay in use. Call Close to stop watching.
+func (cs *CertSource) Watch(interval time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	cs.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := cs.Reload(); err != nil {
+					log.Println("kami: error reloading certificates:", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops watching for changes.
+func (cs *CertSource) Close() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.stop != nil {
+		close(cs.stop)
+		cs.stop = nil
+	}
+	return nil
+}
+
+// GetCertificate returns the best certificate for the given ClientHello.
+// It is suitable for use as tls.Config.GetCertificate.
+func (cs *CertSource) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	set, _ := cs.current.Load().(*certSet)
+	if set == nil || len(set.certs) == 0 {
+		return nil, errors.New("kami: no certificates")
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if cert, ok := set.names[name]; ok {
+		return cert, nil
+	}
+	// try a wildcard for the first label
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := set.names["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	return set.certs[0], nil
+}
+
+// TLSConfig returns a new tls.Config that uses this CertSource for certificates.
+func (cs *CertSource) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: cs.GetCertificate,
+	}
+}
+
+// swap atomically replaces the current certificates.
+// cs.mu must be held.
+func (cs *CertSource) swap() {
+	set := &certSet{
+		certs: make([]*tls.Certificate, 0, len(cs.pairs)),
+		names: make(map[string]*tls.Certificate),
+	}
+	for _, cf := range cs.pairs {
+		set.certs = append(set.certs, cf.cert)
+		for _, name := range certNames(cf.cert) {
+			// earlier certificates win
+			if _, exists := set.names[name]; !exists {
+				set.names[name] = cf.cert
+			}
+		}
+	}
+	cs.current.Store(set)
+}
+
+// load (re)loads this certificate if its files have changed since the last load.
+// It returns true if a new certificate was loaded.
+func (cf *certPair) load() (bool, error) {
+	certInfo, err := os.Stat(cf.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyInfo, err := os.Stat(cf.keyFile)
+	if err != nil {
+		return false, err
+	}
+	if cf.cert != nil &&
+		certInfo.ModTime().Equal(cf.certMod) && certInfo.Size() == cf.certSize &&
+		keyInfo.ModTime().Equal(cf.keyMod) && keyInfo.Size() == cf.keySize {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cf.certFile, cf.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("kami: loading %s: %v", cf.certFile, err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false, fmt.Errorf("kami: parsing %s: %v", cf.certFile, err)
+		}
+	}
+	cf.cert = &cert
+	cf.certMod = certInfo.ModTime()
+	cf.keyMod = keyInfo.ModTime()
+	cf.certSize = certInfo.Size()
+	cf.keySize = keyInfo.Size()
+	return true, nil
+}
+
+// certNames returns the lowercase DNS names a certificate is valid for.
+func certNames(cert *tls.Certificate) []string {
+	leaf := cert.Leaf
+	names := leaf.DNSNames
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = []string{leaf.Subject.CommonName}
+	}
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	return lower
+}
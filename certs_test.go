@@ -0,0 +1,109 @@
+// +build !appengine
+
+package kami_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+func TestCertSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kami-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaultCert, defaultKey := writeCert(t, dir, "default", "default.example.com")
+	wildCert, wildKey := writeCert(t, dir, "wild", "*.example.org")
+
+	certs := kami.NewCertSource()
+	if err := certs.Add(defaultCert, defaultKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.Add(wildCert, wildKey); err != nil {
+		t.Fatal(err)
+	}
+
+	expectCert := func(serverName, cn string) {
+		cert, err := certs.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Leaf.Subject.CommonName != cn {
+			t.Errorf("%s: got certificate %q, want %q", serverName, cert.Leaf.Subject.CommonName, cn)
+		}
+	}
+
+	expectCert("default.example.com", "default.example.com")
+	expectCert("a.example.org", "*.example.org")
+	expectCert("A.EXAMPLE.ORG", "*.example.org")
+	expectCert("unknown.example.net", "default.example.com")
+	expectCert("", "default.example.com")
+
+	// rotate the default certificate
+	writeCert(t, dir, "default", "rotated.example.com")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(defaultCert, later, later)
+	if err := certs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	expectCert("rotated.example.com", "rotated.example.com")
+	expectCert("a.example.org", "*.example.org")
+
+	// broken certificates should keep the old one
+	if err := ioutil.WriteFile(wildCert, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := certs.Reload(); err == nil {
+		t.Error("expected error reloading broken certificate")
+	}
+	expectCert("a.example.org", "*.example.org")
+}
+
+func writeCert(t *testing.T, dir, name, cn string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
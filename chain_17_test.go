@@ -0,0 +1,70 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestChain(t *testing.T) {
+	type key struct{}
+	var trace []string
+	set := func(v string) kami.Middleware {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+			trace = append(trace, v)
+			return context.WithValue(ctx, key{}, v)
+		}
+	}
+	readOnly := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(key{}).(string)
+		trace = append(trace, "read "+v)
+	})
+	halt := func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		trace = append(trace, "halt")
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	isPost := func(r *http.Request) bool { return r.Method == "POST" }
+
+	mux := kami.New()
+	mux.Use("/", kami.Chain(
+		set("a"),
+		readOnly,
+		kami.When(isPost, set("post")),
+		kami.Unless(isPost, set("other")),
+		kami.Chain(set("nested")),
+	))
+	mux.Use("/stop/", kami.Chain(halt, set("unreachable")))
+	mux.Handle(kami.AnyMethod, "/*path", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		v, _ := ctx.Value(key{}).(string)
+		trace = append(trace, "handler "+v)
+	})
+
+	expect := func(method, path string, code int, want ...string) {
+		t.Helper()
+		trace = nil
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest(method, path, nil))
+		if resp.Code != code {
+			t.Errorf("%s %s: want %d, got %d", method, path, code, resp.Code)
+		}
+		if len(trace) != len(want) {
+			t.Errorf("%s %s: want %v, got %v", method, path, want, trace)
+			return
+		}
+		for i := range want {
+			if trace[i] != want[i] {
+				t.Errorf("%s %s: want %v, got %v", method, path, want, trace)
+				return
+			}
+		}
+	}
+	expect("GET", "/x", http.StatusOK, "a", "read a", "other", "nested", "handler nested")
+	expect("POST", "/x", http.StatusOK, "a", "read a", "post", "nested", "handler nested")
+	expect("GET", "/stop/x", http.StatusForbidden, "a", "read a", "other", "nested", "halt")
+}
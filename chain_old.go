@@ -0,0 +1,48 @@
+// +build !go1.7
+
+package kami
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// Chain combines middleware into one Middleware that runs them in order,
+// as if each were given to Use. If one returns nil, the rest are skipped and the chain returns nil,
+// halting the request. Any MiddlewareType is accepted.
+// 	authenticatedAPI := kami.Chain(loadUser, requireLogin, kami.Unless(isGET, checkCSRF))
+// 	kami.Use("/api/", authenticatedAPI)
+func Chain(mws ...MiddlewareType) Middleware {
+	chain := make([]Middleware, len(mws))
+	for i, mw := range mws {
+		chain[i] = convert(mw)
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		for _, mw := range chain {
+			result := mw(ctx, w, r)
+			if result == nil {
+				return nil
+			}
+			ctx = result
+		}
+		return ctx
+	}
+}
+
+// When returns Middleware that runs mw only for requests that match predicate.
+// Other requests pass through untouched.
+func When(predicate func(*http.Request) bool, mw MiddlewareType) Middleware {
+	fn := convert(mw)
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if !predicate(r) {
+			return ctx
+		}
+		return fn(ctx, w, r)
+	}
+}
+
+// Unless returns Middleware that runs mw only for requests that don't match predicate.
+func Unless(predicate func(*http.Request) bool, mw MiddlewareType) Middleware {
+	return When(func(r *http.Request) bool { return !predicate(r) }, mw)
+}
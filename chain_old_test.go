@@ -0,0 +1,74 @@
+// +build !go1.7
+
+package kami_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/guregu/kami"
+)
+
+func TestChain(t *testing.T) {
+	type key struct{}
+	var trace []string
+	set := func(v string) kami.Middleware {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+			trace = append(trace, v)
+			return context.WithValue(ctx, key{}, v)
+		}
+	}
+	// vanilla middleware can't see the context before Go 1.7
+	readOnly := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace = append(trace, "read")
+			next.ServeHTTP(w, r)
+		})
+	}
+	halt := func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		trace = append(trace, "halt")
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	isPost := func(r *http.Request) bool { return r.Method == "POST" }
+
+	mux := kami.New()
+	mux.Use("/", kami.Chain(
+		set("a"),
+		readOnly,
+		kami.When(isPost, set("post")),
+		kami.Unless(isPost, set("other")),
+		kami.Chain(set("nested")),
+	))
+	mux.Use("/stop/", kami.Chain(halt, set("unreachable")))
+	mux.Handle(kami.AnyMethod, "/*path", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		v, _ := ctx.Value(key{}).(string)
+		trace = append(trace, "handler "+v)
+	})
+
+	expect := func(method, path string, code int, want ...string) {
+		trace = nil
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Errorf("%s %s: want %d, got %d", method, path, code, resp.Code)
+		}
+		if len(trace) != len(want) {
+			t.Errorf("%s %s: want %v, got %v", method, path, want, trace)
+			return
+		}
+		for i := range want {
+			if trace[i] != want[i] {
+				t.Errorf("%s %s: want %v, got %v", method, path, want, trace)
+				return
+			}
+		}
+	}
+	expect("GET", "/x", http.StatusOK, "a", "read", "other", "nested", "handler nested")
+	expect("POST", "/x", http.StatusOK, "a", "read", "post", "nested", "handler nested")
+	expect("GET", "/stop/x", http.StatusForbidden, "a", "read", "other", "nested", "halt")
+}
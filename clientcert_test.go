@@ -0,0 +1,68 @@
+// +build go1.10
+
+package kami_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+	billing := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "billing"},
+		DNSNames: []string{"billing.internal.example.com"},
+		URIs:     []*url.URL{spiffe},
+	}
+	stranger := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "stranger"},
+		DNSNames: []string{"stranger.example.net"},
+	}
+
+	mux := kami.New()
+	mux.Use("/internal/", kami.RequireClientCert(kami.ClientCertPolicy{
+		DNSNames: []string{"*.internal.example.com"},
+	}))
+	mux.Use("/internal/billing/", kami.RequireClientCert(kami.ClientCertPolicy{
+		URIs: []string{"spiffe://example.com/billing"},
+	}))
+	mux.Get("/internal/billing/charge", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if chain := kami.ClientCert(ctx); len(chain) == 0 || chain[0] != billing {
+			t.Error("missing client certificate in context")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Get("/public", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if kami.ClientCert(ctx) != nil {
+			t.Error("unexpected client certificate")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	expect := func(path string, cert *x509.Certificate, code int) {
+		req, _ := http.NewRequest("GET", path, nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			}
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Errorf("%s: got code %d, want %d", path, resp.Code, code)
+		}
+	}
+
+	expect("/internal/billing/charge", billing, http.StatusOK)
+	expect("/internal/billing/charge", stranger, http.StatusForbidden)
+	expect("/internal/billing/charge", nil, http.StatusForbidden)
+	expect("/public", nil, http.StatusOK)
+}
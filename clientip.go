@@ -0,0 +1,143 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns the client's IP address as determined by the RealIP middleware.
+// It returns nil if RealIP hasn't run for this request.
+func ClientIP(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(clientIPKey{}).(net.IP)
+	return ip
+}
+
+// RealIP returns middleware that finds the real client IP address, for use with ClientIP.
+// Forwarding headers are only believed when they were added by the given trusted proxies,
+// specified as CIDR networks or IP addresses, so clients can't spoof their address.
+// The forwarding chain is walked from the most recent hop backwards, and the first address
+// that isn't a trusted proxy is the client. If a hop is obfuscated, such as "for=_hidden",
+// or can't be parsed, the client is unknown and ClientIP returns nil.
+//
+// RealIP looks for RFC 7239 Forwarded, X-Forwarded-For, or X-Real-IP headers.
+// It can't tell which of them your proxies set, so if a request has more than one kind,
+// the client is unknown. Use RealIPFrom to name the header your proxies set instead.
+// RealIP panics if a trusted network is invalid.
+// 	kami.Use("/", kami.RealIP("10.0.0.0/8", "fd00::/8"))
+func RealIP(trusted ...string) Middleware {
+	return realIP("", trusted)
+}
+
+// RealIPFrom is like RealIP, but only believes the given header, which should be the one your
+// trusted proxies set: "Forwarded", "X-Forwarded-For", "X-Real-IP", or another header
+// with a comma-separated list of addresses. Other forwarding headers are ignored,
+// so clients can't use them to spoof their address.
+// 	kami.Use("/", kami.RealIPFrom("X-Forwarded-For", "10.0.0.0/8"))
+func RealIPFrom(header string, trusted ...string) Middleware {
+	return realIP(http.CanonicalHeaderKey(header), trusted)
+}
+
+func realIP(header string, trusted []string) Middleware {
+	nets, err := parseNetworks(trusted)
+	if err != nil {
+		panic(err)
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		ip := clientIP(r, nets, header)
+		if ip == nil {
+			return ctx
+		}
+		return context.WithValue(ctx, clientIPKey{}, ip)
+	}
+}
+
+var forwardingHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-Ip"}
+
+// clientIP finds the client IP for r, trusting forwarding headers from the given networks.
+// If header is blank, any one of the standard forwarding headers is used.
+func clientIP(r *http.Request, trusted []*net.IPNet, header string) net.IP {
+	peer := parseHost(r.RemoteAddr)
+	if peer == nil || !containsIP(trusted, peer) {
+		return peer
+	}
+
+	if header == "" {
+		for _, h := range forwardingHeaders {
+			if len(r.Header[h]) == 0 {
+				continue
+			}
+			if header != "" {
+				// a client may have added one of them; we can't tell which
+				return nil
+			}
+			header = h
+		}
+	}
+
+	var hops []string
+	switch values := r.Header[header]; header {
+	case "":
+	case "Forwarded":
+		hops = forwardedFor(values)
+	default:
+		for _, h := range values {
+			hops = append(hops, strings.Split(h, ",")...)
+		}
+	}
+
+	// walk backwards from the hop closest to us
+	ip := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHost(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// obfuscated or garbage: whoever is behind it is unknown
+			return nil
+		}
+		ip = hop
+		if !containsIP(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the "for" parameters of RFC 7239 Forwarded headers, in order.
+// Elements without a "for" parameter are returned as blank strings.
+func forwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, elem := range strings.Split(header, ",") {
+			var hop string
+			for _, pair := range strings.Split(elem, ";") {
+				eq := strings.IndexByte(pair, '=')
+				if eq == -1 {
+					continue
+				}
+				if strings.EqualFold(strings.TrimSpace(pair[:eq]), "for") {
+					hop = strings.Trim(strings.TrimSpace(pair[eq+1:]), `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseHost parses an IP address that may have a port, such as "192.0.2.1:80" or "[2001:db8::1]:80".
+func parseHost(addr string) net.IP {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		// bracketed IPv6 without a port
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,86 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestRealIP(t *testing.T) {
+	muxes := make(map[string]*kami.Mux)
+	for _, from := range []string{"", "Forwarded", "X-Forwarded-For"} {
+		mux := kami.New()
+		if from == "" {
+			mux.Use("/", kami.RealIP("10.0.0.0/8", "fd00::/8"))
+		} else {
+			mux.Use("/", kami.RealIPFrom(from, "10.0.0.0/8", "fd00::/8"))
+		}
+		mux.Get("/ip", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if ip := kami.ClientIP(ctx); ip != nil {
+				io.WriteString(w, ip.String())
+			}
+		})
+		muxes[from] = mux
+	}
+
+	tests := []struct {
+		from   string
+		remote string
+		header http.Header
+		want   string
+	}{
+		// no proxy
+		{"", "192.0.2.1:1234", nil, "192.0.2.1"},
+		// spoofed headers from untrusted clients are ignored
+		{"", "192.0.2.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}}, "192.0.2.1"},
+		{"", "192.0.2.1:1234", http.Header{"X-Real-Ip": {"1.2.3.4"}}, "192.0.2.1"},
+		// trusted proxy
+		{"", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"192.0.2.2"}}, "192.0.2.2"},
+		{"", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"192.0.2.2"}}, "192.0.2.2"},
+		// client tries to spoof, trusted proxies append the real address
+		{"", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4, 192.0.2.3, 10.0.0.2"}}, "192.0.2.3"},
+		{"", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4", "192.0.2.3, 10.0.0.2"}}, "192.0.2.3"},
+		// everyone is trusted
+		{"", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		// RFC 7239
+		{"Forwarded", "10.0.0.1:1234", http.Header{
+			"Forwarded":       {`for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`},
+			"X-Forwarded-For": {"5.6.7.8"},
+		}, "2001:db8:cafe::17"},
+		{"", "[fd00::1]:1234", http.Header{"Forwarded": {"for=192.0.2.4;by=fd00::1"}}, "192.0.2.4"},
+		// obfuscated identifiers
+		{"", "10.0.0.1:1234", http.Header{"Forwarded": {"for=_hidden, for=10.0.0.2"}}, ""},
+		{"", "10.0.0.1:1234", http.Header{"Forwarded": {"for=_hidden"}}, ""},
+		{"", "10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.5, for=_hidden, for=10.0.0.2"}}, ""},
+		// client adds a header the proxy doesn't set
+		{"", "10.0.0.1:1234", http.Header{
+			"Forwarded":       {"for=6.6.6.6"},
+			"X-Forwarded-For": {"203.0.113.9"},
+		}, ""},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{
+			"Forwarded":       {"for=6.6.6.6"},
+			"X-Forwarded-For": {"203.0.113.9"},
+		}, "203.0.113.9"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"6.6.6.6"}}, "10.0.0.1"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6"}}, "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+		resp := httptest.NewRecorder()
+		muxes[test.from].ServeHTTP(resp, req)
+		if got := resp.Body.String(); got != test.want {
+			t.Errorf("%q %s %v: got %q, want %q", test.from, test.remote, test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// +build go1.7
+
+package kami
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is a table of routes and middleware, such as one loaded from a file,
+// that refers to handlers and middleware registered with Register by name.
+// This lets routes be disabled, moved, or limited without changing code.
+//
+// ReadConfig reads a Config from JSON, YAML, TOML, or anything else with an Unmarshal function.
+type Config struct {
+	Routes     []RouteConfig      `json:"routes" yaml:"routes" toml:"routes"`
+	Middleware []MiddlewareConfig `json:"middleware,omitempty" yaml:"middleware,omitempty" toml:"middleware,omitempty"`
+}
+
+// RouteConfig is a route in a Config.
+type RouteConfig struct {
+	Method string `json:"method" yaml:"method" toml:"method"`
+	Path   string `json:"path" yaml:"path" toml:"path"`
+	// Handler is the name of a handler registered with Register.
+	Handler string `json:"handler" yaml:"handler" toml:"handler"`
+	// Disabled routes are skipped.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty" toml:"disabled,omitempty"`
+	// Timeout, if set, overrides other timeouts for this route. See WithTimeout.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+}
+
+// MiddlewareConfig binds middleware to a path in a Config.
+type MiddlewareConfig struct {
+	Path string `json:"path" yaml:"path" toml:"path"`
+	// Use is the names of middleware registered with Register, run in order as if given to Use.
+	Use []string `json:"use,omitempty" yaml:"use,omitempty" toml:"use,omitempty"`
+	// Methods and Except restrict the middleware in Use, as with the Methods and Except options.
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty" toml:"methods,omitempty"`
+	Except  []string `json:"except,omitempty" yaml:"except,omitempty" toml:"except,omitempty"`
+	// After is the names of afterware registered with Register, as if given to After.
+	After []string `json:"after,omitempty" yaml:"after,omitempty" toml:"after,omitempty"`
+	// Limit, if set, limits requests to this path and every path under it. See Limit.
+	Limit *LimitConfig `json:"limit,omitempty" yaml:"limit,omitempty" toml:"limit,omitempty"`
+}
+
+// LimitConfig configures a Limiter in a Config.
+type LimitConfig struct {
+	Max          int      `json:"max" yaml:"max" toml:"max"`
+	QueueSize    int      `json:"queue_size,omitempty" yaml:"queue_size,omitempty" toml:"queue_size,omitempty"`
+	QueueTimeout Duration `json:"queue_timeout,omitempty" yaml:"queue_timeout,omitempty" toml:"queue_timeout,omitempty"`
+	RetryAfter   Duration `json:"retry_after,omitempty" yaml:"retry_after,omitempty" toml:"retry_after,omitempty"`
+}
+
+// Duration is a time.Duration written in configs as a string, such as "30s" or "1m30s".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// ConfigError lists the problems found in a Config.
+type ConfigError struct {
+	Problems []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, err := range e.Problems {
+		msgs[i] = err.Error()
+	}
+	return "kami: bad config: " + strings.Join(msgs, "; ")
+}
+
+var registry = struct {
+	sync.RWMutex
+	named map[string]interface{}
+}{named: make(map[string]interface{})}
+
+// Register names a handler, middleware, or afterware so that it can be referred to in a Config.
+// It panics if the name is taken.
+// 	kami.Register("users.show", showUser)
+// 	kami.Register("auth", requireLogin)
+func Register(name string, handlerOrMiddleware interface{}) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.named[name]; ok {
+		panic("kami: Register called twice for " + name)
+	}
+	registry.named[name] = handlerOrMiddleware
+}
+
+func registered(name string) (interface{}, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	v, ok := registry.named[name]
+	return v, ok
+}
+
+// ReadConfig reads a Config, decoding it with unmarshal.
+// Use json.Unmarshal for JSON, or the Unmarshal function of a YAML or TOML package:
+// 	cfg, err := kami.ReadConfig(file, json.Unmarshal)
+// 	cfg, err := kami.ReadConfig(file, yaml.Unmarshal)
+// 	cfg, err := kami.ReadConfig(file, toml.Unmarshal)
+func ReadConfig(r io.Reader, unmarshal func([]byte, interface{}) error) (Config, error) {
+	var cfg Config
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return cfg, err
+	}
+	err = unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// LoadConfig registers a Config's routes and middleware with the global router.
+// Every name must be registered with Register and every route must be unique.
+// If there are any problems, nothing is registered and a *ConfigError listing them is returned.
+//
+// Middleware is registered first, so that no request reaches a route without it.
+// Like Use, it isn't safe while serving requests, so call LoadConfig before serving.
+// The routes are then added all at once, as with Update.
+func LoadConfig(cfg Config) error {
+	return loadConfig(cfg, table, bless, defaultMW)
+}
+
+// LoadConfig registers a Config's routes and middleware with this mux.
+// See the global LoadConfig function's documents for more information.
+func (m *Mux) LoadConfig(cfg Config) error {
+	return loadConfig(cfg, m.table, m.bless, m.wares)
+}
+
+func loadConfig(cfg Config, t *routeTable, bless blessFunc, mw *wares) error {
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	type configRoute struct {
+		method, path string
+		handler      HandlerType
+	}
+	var routes []configRoute
+	seen := make(map[string]bool)
+	for _, rc := range cfg.Routes {
+		if rc.Disabled {
+			continue
+		}
+		method := strings.ToUpper(rc.Method)
+		key := method + " " + rc.Path
+		switch {
+		case method == "" || rc.Path == "":
+			problem("route %q: missing method or path", key)
+			continue
+		case seen[key]:
+			problem("route %s: duplicate route", key)
+			continue
+		}
+		seen[key] = true
+		v, ok := registered(rc.Handler)
+		if !ok {
+			problem("route %s: unknown handler %q", key, rc.Handler)
+			continue
+		}
+		var h HandlerType
+		if err := recoverError(func() { h = wrap(v) }); err != nil {
+			problem("route %s: handler %q: %v", key, rc.Handler, err)
+			continue
+		}
+		if rc.Timeout != 0 {
+			h = WithTimeout(h, time.Duration(rc.Timeout))
+		}
+		routes = append(routes, configRoute{method: method, path: rc.Path, handler: h})
+	}
+
+	type binding struct {
+		path  string
+		use   []Middleware
+		opts  []UseOption
+		after []Afterware
+		limit *Limiter
+	}
+	var bindings []binding
+	for _, mc := range cfg.Middleware {
+		b := binding{path: mc.Path}
+		if mc.Path == "" {
+			problem("middleware: missing path")
+			continue
+		}
+		if len(mc.Methods) > 0 {
+			b.opts = append(b.opts, Methods(mc.Methods...))
+		}
+		if len(mc.Except) > 0 {
+			b.opts = append(b.opts, Except(mc.Except...))
+		}
+		for _, name := range mc.Use {
+			v, ok := registered(name)
+			if !ok {
+				problem("middleware %s: unknown middleware %q", mc.Path, name)
+				continue
+			}
+			if err := recoverError(func() { b.use = append(b.use, convert(v)) }); err != nil {
+				problem("middleware %s: %q: %v", mc.Path, name, err)
+			}
+		}
+		for _, name := range mc.After {
+			v, ok := registered(name)
+			if !ok {
+				problem("middleware %s: unknown afterware %q", mc.Path, name)
+				continue
+			}
+			if err := recoverError(func() { b.after = append(b.after, convertAW(v)) }); err != nil {
+				problem("middleware %s: %q: %v", mc.Path, name, err)
+			}
+		}
+		if lc := mc.Limit; lc != nil {
+			if containsWildcard(mc.Path) {
+				problem("middleware %s: limits don't support wildcard paths", mc.Path)
+			}
+			if lc.Max < 1 {
+				problem("middleware %s: limit max must be at least 1", mc.Path)
+			}
+			b.limit = &Limiter{
+				Max:          lc.Max,
+				QueueSize:    lc.QueueSize,
+				QueueTimeout: time.Duration(lc.QueueTimeout),
+				RetryAfter:   time.Duration(lc.RetryAfter),
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	err := t.updateAfter(bless, func(u *RouteUpdate) {
+		for _, rt := range routes {
+			u.Handle(rt.method, rt.path, rt.handler)
+		}
+	}, func() error {
+		for _, b := range bindings {
+			for _, m := range b.use {
+				if err := mw.TryUse(b.path, m, b.opts...); err != nil {
+					return err
+				}
+			}
+			for _, a := range b.after {
+				if err := mw.TryAfter(b.path, a); err != nil {
+					return err
+				}
+			}
+			if b.limit != nil {
+				mw.Limit(b.path, b.limit)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return &ConfigError{Problems: []error{err}}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/guregu/kami"
+)
+
+func TestLoadConfig(t *testing.T) {
+	kami.Register("config.show", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("want timeout from config")
+		}
+		w.Write([]byte("user " + kami.Param(ctx, "id")))
+	})
+	kami.Register("config.list", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	kami.Register("config.auth", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return nil
+		}
+		return ctx
+	})
+	kami.Register("config.deny", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	})
+	kami.Register("config.bogus", 42)
+
+	cfg, err := kami.ReadConfig(strings.NewReader(`{
+		"routes": [
+			{"method": "get", "path": "/v2/users/:id", "handler": "config.show", "timeout": "5s"},
+			{"method": "GET", "path": "/v2/users", "handler": "config.list"},
+			{"method": "GET", "path": "/users", "handler": "config.list", "disabled": true}
+		],
+		"middleware": [
+			{"path": "/v2/", "use": ["config.auth"], "limit": {"max": 10, "queue_timeout": "100ms"}}
+		]
+	}`), json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := kami.New()
+	if err := mux.LoadConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(path, auth string, code int, body string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code || (body != "" && resp.Body.String() != body) {
+			t.Errorf("%s: want %d %q, got %d %q", path, code, body, resp.Code, resp.Body.String())
+		}
+	}
+	expect("/v2/users/1", "token", http.StatusOK, "user 1")
+	expect("/v2/users", "token", http.StatusOK, "users")
+	expect("/v2/users", "", http.StatusUnauthorized, "")
+	expect("/users", "token", http.StatusNotFound, "")
+
+	// nothing is registered if anything is wrong
+	bad := kami.Config{
+		Routes: []kami.RouteConfig{
+			{Method: "GET", Path: "/a", Handler: "config.list"},
+			{Method: "GET", Path: "/a", Handler: "config.list"},
+			{Method: "GET", Path: "/b", Handler: "config.missing"},
+			{Method: "GET", Path: "/c", Handler: "config.bogus"},
+		},
+		Middleware: []kami.MiddlewareConfig{
+			{Path: "/", Use: []string{"config.nope"}},
+			{Path: "/:id", Limit: &kami.LimitConfig{Max: 1}},
+		},
+	}
+	err = mux.LoadConfig(bad)
+	cerr, ok := err.(*kami.ConfigError)
+	if !ok {
+		t.Fatal("want ConfigError, got", err)
+	}
+	if len(cerr.Problems) != 5 {
+		t.Error("want 5 problems, got", cerr)
+	}
+	for _, want := range []string{"duplicate", "config.missing", "config.bogus", "config.nope", "wildcard"} {
+		if !strings.Contains(cerr.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", cerr, want)
+		}
+	}
+	expect("/a", "", http.StatusNotFound, "")
+
+	// conflicts with existing routes
+	conflict := kami.Config{
+		Routes: []kami.RouteConfig{
+			{Method: "GET", Path: "/fresh", Handler: "config.list"},
+			{Method: "GET", Path: "/v2/users", Handler: "config.list"},
+		},
+		Middleware: []kami.MiddlewareConfig{
+			{Path: "/v2/", Use: []string{"config.deny"}},
+		},
+	}
+	if err := mux.LoadConfig(conflict); err == nil || !strings.Contains(err.Error(), "conflicts with GET /v2/users") {
+		t.Error("want conflict error, got", err)
+	}
+	expect("/fresh", "", http.StatusNotFound, "")
+	expect("/v2/users", "token", http.StatusOK, "users")
+}
+
+func TestReadConfig(t *testing.T) {
+	kami.Register("readconfig.show", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + kami.Param(ctx, "id")))
+	})
+	kami.Register("readconfig.auth", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return nil
+		}
+		return ctx
+	})
+
+	want := kami.Config{
+		Routes: []kami.RouteConfig{
+			{Method: "GET", Path: "/users/:id", Handler: "readconfig.show", Timeout: kami.Duration(5 * time.Second)},
+			{Method: "GET", Path: "/old/:id", Handler: "readconfig.show", Disabled: true},
+		},
+		Middleware: []kami.MiddlewareConfig{
+			{
+				Path:    "/users/",
+				Use:     []string{"readconfig.auth"},
+				Methods: []string{"GET"},
+				Limit:   &kami.LimitConfig{Max: 10, QueueSize: 5, QueueTimeout: kami.Duration(100 * time.Millisecond)},
+			},
+		},
+	}
+	sources := []struct {
+		format    string
+		text      string
+		unmarshal func([]byte, interface{}) error
+	}{
+		{"JSON", `{
+			"routes": [
+				{"method": "GET", "path": "/users/:id", "handler": "readconfig.show", "timeout": "5s"},
+				{"method": "GET", "path": "/old/:id", "handler": "readconfig.show", "disabled": true}
+			],
+			"middleware": [
+				{"path": "/users/", "use": ["readconfig.auth"], "methods": ["GET"],
+				 "limit": {"max": 10, "queue_size": 5, "queue_timeout": "100ms"}}
+			]
+		}`, json.Unmarshal},
+		{"YAML", `
+routes:
+  - method: GET
+    path: /users/:id
+    handler: readconfig.show
+    timeout: 5s
+  - method: GET
+    path: /old/:id
+    handler: readconfig.show
+    disabled: true
+middleware:
+  - path: /users/
+    use: [readconfig.auth]
+    methods: [GET]
+    limit:
+      max: 10
+      queue_size: 5
+      queue_timeout: 100ms
+`, yaml.Unmarshal},
+		{"TOML", `
+[[routes]]
+method = "GET"
+path = "/users/:id"
+handler = "readconfig.show"
+timeout = "5s"
+
+[[routes]]
+method = "GET"
+path = "/old/:id"
+handler = "readconfig.show"
+disabled = true
+
+[[middleware]]
+path = "/users/"
+use = ["readconfig.auth"]
+methods = ["GET"]
+limit = { max = 10, queue_size = 5, queue_timeout = "100ms" }
+`, toml.Unmarshal},
+	}
+
+	for _, src := range sources {
+		cfg, err := kami.ReadConfig(strings.NewReader(src.text), src.unmarshal)
+		if err != nil {
+			t.Errorf("%s: %v", src.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: got %+v, want %+v", src.format, cfg, want)
+		}
+
+		mux := kami.New()
+		if err := mux.LoadConfig(cfg); err != nil {
+			t.Errorf("%s: %v", src.format, err)
+			continue
+		}
+		for _, tc := range []struct {
+			path, auth string
+			code       int
+		}{
+			{"/users/1", "token", http.StatusOK},
+			{"/users/1", "", http.StatusUnauthorized},
+			{"/old/1", "token", http.StatusNotFound},
+		} {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, req)
+			if resp.Code != tc.code {
+				t.Errorf("%s: %s: want %d, got %d", src.format, tc.path, tc.code, resp.Code)
+			}
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	kami.Register("config.twice", func(w http.ResponseWriter, r *http.Request) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic")
+		}
+	}()
+	kami.Register("config.twice", func(w http.ResponseWriter, r *http.Request) {})
+}
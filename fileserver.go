@@ -0,0 +1,305 @@
+// +build go1.16
+
+package kami
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileServerOptions changes how FileServer serves files.
+// The zero value serves index.html for directories and nothing else special.
+type FileServerOptions struct {
+	// Index is the file served for directories. Defaults to "index.html".
+	Index string
+	// NoIndex disables index files. Directories are not found unless ListDirectories is set.
+	NoIndex bool
+	// ListDirectories serves a listing of directories that don't have an index file.
+	ListDirectories bool
+
+	// Precompressed serves files compressed ahead of time, with the same name plus ".br" or ".gz",
+	// to clients that accept them.
+	Precompressed bool
+	// Immutable reports whether a file never changes, such as an asset with a hash of its contents in its name.
+	// Immutable files are sent with a Cache-Control header allowing clients to cache them for a year.
+	// See HashedName.
+	Immutable func(name string) bool
+
+	// SPA serves the root index file for missing paths without a file extension,
+	// so that single-page apps can do their own routing.
+	// Missing files with an extension, such as "/app.js", are still not found.
+	SPA bool
+}
+
+// FileServer returns a handler that serves files from fsys, such as an embed.FS or os.DirFS.
+// Register it with a catch-all route whose parameter is named "path",
+// and with the route's prefix too to serve the root directory:
+// 	static := kami.FileServer(assets)
+// 	kami.Get("/static/*path", static)
+// 	kami.Get("/static/", static)
+// It supports conditional and Range requests using ETag and Last-Modified.
+// Files in an embed.FS don't have modification times, so their ETags are hashes of their contents,
+// computed once. Other files without modification times are hashed for every request,
+// since they might change.
+// Requests for missing files are passed to the NotFound handler of the mux the route belongs to,
+// without running middleware a second time.
+func FileServer(fsys fs.FS, options ...FileServerOptions) ContextHandler {
+	var opts FileServerOptions
+	switch len(options) {
+	case 0:
+	case 1:
+		opts = options[0]
+	default:
+		panic("kami: FileServer takes at most one FileServerOptions")
+	}
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+	fsrv := &fileServer{fsys: fsys, opts: opts}
+	switch fsys.(type) {
+	case embed.FS, *embed.FS:
+		fsrv.immutable = true
+	}
+	return fsrv
+}
+
+type fileServer struct {
+	fsys      fs.FS
+	opts      FileServerOptions
+	immutable bool     // fsys is an embed.FS, whose files never change
+	tags      sync.Map // name → ETag, for immutable files without modification times
+}
+
+func (*fileServer) fallsBackToNotFound() {}
+
+func (fsrv *fileServer) ServeHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + Param(ctx, "path"))[1:]
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(fsrv.fsys, name)
+	switch {
+	case err != nil:
+		if fsrv.opts.SPA && path.Ext(name) == "" && fsrv.serveIndex(ctx, w, r, ".", true) {
+			return
+		}
+		fsrv.notFound(ctx, w, r)
+	case info.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// relative links in the index need the slash
+			u := *r.URL
+			u.Path += "/"
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+		if fsrv.serveIndex(ctx, w, r, name, false) {
+			return
+		}
+		if fsrv.opts.ListDirectories {
+			fsrv.list(ctx, w, r, name)
+			return
+		}
+		fsrv.notFound(ctx, w, r)
+	default:
+		fsrv.serveFile(ctx, w, r, name, info)
+	}
+}
+
+// serveIndex serves the index file of a directory, reporting whether there was one.
+// The SPA fallback isn't cached, because it stands in for paths the app decides on.
+func (fsrv *fileServer) serveIndex(ctx context.Context, w http.ResponseWriter, r *http.Request, dir string, fallback bool) bool {
+	if fsrv.opts.NoIndex {
+		return false
+	}
+	name := path.Join(dir, fsrv.opts.Index)
+	info, err := fs.Stat(fsrv.fsys, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if fallback {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	fsrv.serveFile(ctx, w, r, name, info)
+	return true
+}
+
+// precompressed lists the encodings of precompressed files, in order of preference.
+var precompressed = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (fsrv *fileServer) serveFile(ctx context.Context, w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	header := w.Header()
+	ctype := mime.TypeByExtension(path.Ext(name))
+
+	served := name
+	if fsrv.opts.Precompressed {
+		for _, pc := range precompressed {
+			cinfo, err := fs.Stat(fsrv.fsys, name+pc.ext)
+			if err != nil || cinfo.IsDir() {
+				continue
+			}
+			header.Set("Vary", "Accept-Encoding")
+			if acceptsEncoding(r.Header.Get("Accept-Encoding"), pc.encoding) {
+				header.Set("Content-Encoding", pc.encoding)
+				served, info = name+pc.ext, cinfo
+				break
+			}
+		}
+		if ctype == "" && served != name {
+			ctype = "application/octet-stream"
+		}
+	}
+
+	f, err := fsrv.fsys.Open(served)
+	if err != nil {
+		fsrv.notFound(ctx, w, r)
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if ctype != "" {
+		// otherwise ServeContent would sniff compressed data
+		header.Set("Content-Type", ctype)
+	}
+	etag, err := fsrv.etag(served, info, content)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	header.Set("ETag", etag)
+	if fsrv.opts.Immutable != nil && fsrv.opts.Immutable(name) {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// etag returns the ETag for a file.
+// Files with modification times use them and their size, while others are hashed.
+// Hashes are only remembered for an embed.FS, as other filesystems may change a file without a trace.
+func (fsrv *fileServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if mod := info.ModTime(); !mod.IsZero() {
+		return `"` + strconv.FormatInt(mod.UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`, nil
+	}
+	if fsrv.immutable {
+		if tag, ok := fsrv.tags.Load(name); ok {
+			return tag.(string), nil
+		}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	tag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	if fsrv.immutable {
+		fsrv.tags.Store(name, tag)
+	}
+	return tag, nil
+}
+
+// list serves a simple HTML listing of a directory.
+func (fsrv *fileServer) list(ctx context.Context, w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(fsrv.fsys, name)
+	if err != nil {
+		fsrv.notFound(ctx, w, r)
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// notFound calls the mux's NotFound handler, or http.NotFound outside of kami.
+func (fsrv *fileServer) notFound(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// don't leak headers meant for the file
+	for _, key := range []string{"Cache-Control", "Content-Encoding", "Content-Type", "ETag", "Vary"} {
+		w.Header().Del(key)
+	}
+	if h, ok := ctx.Value(notFoundKey{}).(ContextHandler); ok && h != nil {
+		h.ServeHTTPContext(ctx, w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// acceptsEncoding returns true if an Accept-Encoding header allows the given content coding.
+func acceptsEncoding(accept, encoding string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if (coding == encoding || coding == "*") && !qZero(params[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// HashedName reports whether a file name includes what looks like a hash of its contents,
+// such as "app.3f9a2c1b.js" or "index-BdG3x9aZ.css", as produced by most asset bundlers.
+// Use it as FileServerOptions.Immutable.
+func HashedName(name string) bool {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	for _, part := range strings.FieldsFunc(base, func(r rune) bool { return r == '.' || r == '-' }) {
+		if len(part) >= 8 && isHash(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHash returns true if s is made of letters, digits, and underscores, including at least one digit.
+func isHash(s string) bool {
+	digit := false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		default:
+			return false
+		}
+	}
+	return digit
+}
@@ -0,0 +1,157 @@
+// +build go1.16
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+func TestFileServer(t *testing.T) {
+	modtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := fstest.MapFS{
+		"index.html":           {Data: []byte("<h1>home</h1>")},
+		"app.3f9a2c1b.js":      {Data: []byte("console.log('hi')"), ModTime: modtime},
+		"app.3f9a2c1b.js.br":   {Data: []byte("brotli"), ModTime: modtime},
+		"app.3f9a2c1b.js.gz":   {Data: []byte("gzip"), ModTime: modtime},
+		"docs/readme.txt":      {Data: []byte("0123456789")},
+		"docs/guide/page.html": {Data: []byte("guide")},
+	}
+
+	var mwRuns int
+	mux := kami.New()
+	mux.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		mwRuns++
+		return ctx
+	})
+	mux.NotFound(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	static := kami.FileServer(files, kami.FileServerOptions{
+		Precompressed: true,
+		Immutable:     kami.HashedName,
+	})
+	mux.Get("/static/*path", static)
+	mux.Get("/static/", static)
+	mux.Get("/app/*path", kami.FileServer(files, kami.FileServerOptions{SPA: true}))
+	browse := kami.FileServer(files, kami.FileServerOptions{NoIndex: true, ListDirectories: true})
+	mux.Get("/browse/*path", browse)
+	mux.Get("/browse/", browse)
+
+	serve := func(path string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("/static/docs/readme.txt")
+	if resp.Code != 200 || resp.Body.String() != "0123456789" {
+		t.Errorf("want readme, got %d %q", resp.Code, resp.Body.String())
+	}
+	etag := resp.Header().Get("ETag")
+	if etag == "" {
+		t.Error("no ETag for file without modtime")
+	}
+	if resp := serve("/static/docs/readme.txt", "If-None-Match", etag); resp.Code != http.StatusNotModified {
+		t.Error("want 304 for matching ETag, got", resp.Code)
+	}
+	if resp := serve("/static/docs/readme.txt", "Range", "bytes=2-4"); resp.Code != http.StatusPartialContent || resp.Body.String() != "234" {
+		t.Errorf("want partial content 234, got %d %q", resp.Code, resp.Body.String())
+	}
+	// same size, new contents
+	files["docs/readme.txt"].Data = []byte("9876543210")
+	if resp := serve("/static/docs/readme.txt", "If-None-Match", etag); resp.Code != http.StatusOK || resp.Header().Get("ETag") == etag {
+		t.Errorf("want a new ETag and 200 after the file changed, got %d %s", resp.Code, resp.Header().Get("ETag"))
+	}
+
+	// precompressed and immutable
+	resp = serve("/static/app.3f9a2c1b.js", "Accept-Encoding", "gzip, br")
+	if resp.Body.String() != "brotli" || resp.Header().Get("Content-Encoding") != "br" {
+		t.Errorf("want brotli, got %q (%s)", resp.Body.String(), resp.Header().Get("Content-Encoding"))
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" && ct != "application/javascript" {
+		t.Error("wrong content type:", ct)
+	}
+	if resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("missing Vary header")
+	}
+	if resp.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Error("hashed asset not immutable:", resp.Header().Get("Cache-Control"))
+	}
+	if resp.Header().Get("Last-Modified") != modtime.Format(http.TimeFormat) {
+		t.Error("wrong Last-Modified:", resp.Header().Get("Last-Modified"))
+	}
+	if resp := serve("/static/app.3f9a2c1b.js", "Accept-Encoding", "gzip, br;q=0"); resp.Body.String() != "gzip" {
+		t.Errorf("want gzip, got %q", resp.Body.String())
+	}
+	if resp := serve("/static/app.3f9a2c1b.js"); resp.Body.String() != "console.log('hi')" || resp.Header().Get("Content-Encoding") != "" {
+		t.Errorf("want uncompressed, got %q", resp.Body.String())
+	}
+
+	// directories
+	if resp := serve("/static/"); resp.Body.String() != "<h1>home</h1>" {
+		t.Errorf("want index, got %d %q", resp.Code, resp.Body.String())
+	}
+	if resp := serve("/static/docs"); resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "/static/docs/" {
+		t.Errorf("want redirect to /static/docs/, got %d %q", resp.Code, resp.Header().Get("Location"))
+	}
+	if resp := serve("/static/docs/"); resp.Code != http.StatusTeapot {
+		t.Error("want NotFound for directory without index, got", resp.Code)
+	}
+	resp = serve("/browse/docs/")
+	if resp.Code != 200 || resp.Body.String() == "" {
+		t.Error("want directory listing, got", resp.Code)
+	}
+	if resp := serve("/browse/"); resp.Code != 200 || resp.Body.String() == "<h1>home</h1>" {
+		t.Error("want listing instead of index, got", resp.Code, resp.Body.String())
+	}
+
+	// misses
+	mwRuns = 0
+	if resp := serve("/static/missing.txt"); resp.Code != http.StatusTeapot {
+		t.Error("want NotFound handler, got", resp.Code)
+	}
+	if mwRuns != 1 {
+		t.Error("want middleware to run once, ran", mwRuns)
+	}
+	if resp := serve("/static/../../etc/passwd"); resp.Code != http.StatusTeapot && resp.Code != http.StatusMovedPermanently {
+		t.Error("escaped the file system:", resp.Code)
+	}
+
+	// SPA
+	resp = serve("/app/users/123")
+	if resp.Body.String() != "<h1>home</h1>" || resp.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("want SPA fallback, got %d %q", resp.Code, resp.Body.String())
+	}
+	if resp := serve("/app/missing.js"); resp.Code != http.StatusTeapot {
+		t.Error("want NotFound for missing asset, got", resp.Code)
+	}
+}
+
+func TestHashedName(t *testing.T) {
+	tests := map[string]bool{
+		"app.3f9a2c1b.js":           true,
+		"assets/index-BdG3x9aZ.css": true,
+		"chunk.0123456789.js":       true,
+		"app.js":                    false,
+		"bootstrap.min.css":         false,
+		"jquery-3.6.0.js":           false,
+		"background.png":            false,
+	}
+	for name, want := range tests {
+		if got := kami.HashedName(name); got != want {
+			t.Errorf("HashedName(%q): want %v, got %v", name, want, got)
+		}
+	}
+}
@@ -7,14 +7,16 @@ import (
 )
 
 var (
-	routes    = newRouter()
+	table     = newRouteTable()
 	enable405 = true
 )
 
 func init() {
-	// set up the default 404/405 handlers
+	// set up the default 404/405/406/415 handlers
 	NotFound(nil)
 	MethodNotAllowed(nil)
+	NotAcceptable(nil)
+	UnsupportedMediaType(nil)
 }
 
 func newRouter() *httptreemux.TreeMux {
@@ -29,47 +31,78 @@ func newRouter() *httptreemux.TreeMux {
 
 // Handler returns an http.Handler serving registered routes.
 func Handler() http.Handler {
-	return routes
+	return table
 }
 
 // Handle registers an arbitrary method handler under the given path.
-func Handle(method, path string, handler HandlerType) {
-	routes.Handle(method, path, bless(wrap(handler)))
+// Optional matchers add conditions for this handler to run, see Matcher.
+func Handle(method, path string, handler HandlerType, matchers ...Matcher) {
+	if err := table.handle(method, path, handler, bless, matchers); err != nil {
+		panic(err)
+	}
 }
 
 // Get registers a GET handler under the given path.
-func Get(path string, handler HandlerType) {
-	Handle("GET", path, handler)
+func Get(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("GET", path, handler, matchers...)
 }
 
 // Post registers a POST handler under the given path.
-func Post(path string, handler HandlerType) {
-	Handle("POST", path, handler)
+func Post(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("POST", path, handler, matchers...)
 }
 
 // Put registers a PUT handler under the given path.
-func Put(path string, handler HandlerType) {
-	Handle("PUT", path, handler)
+func Put(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("PUT", path, handler, matchers...)
 }
 
 // Patch registers a PATCH handler under the given path.
-func Patch(path string, handler HandlerType) {
-	Handle("PATCH", path, handler)
+func Patch(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("PATCH", path, handler, matchers...)
 }
 
 // Head registers a HEAD handler under the given path.
-func Head(path string, handler HandlerType) {
-	Handle("HEAD", path, handler)
+func Head(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("HEAD", path, handler, matchers...)
 }
 
 // Head registers a OPTIONS handler under the given path.
-func Options(path string, handler HandlerType) {
-	Handle("OPTIONS", path, handler)
+func Options(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("OPTIONS", path, handler, matchers...)
 }
 
 // Delete registers a DELETE handler under the given path.
-func Delete(path string, handler HandlerType) {
-	Handle("DELETE", path, handler)
+func Delete(path string, handler HandlerType, matchers ...Matcher) {
+	Handle("DELETE", path, handler, matchers...)
+}
+
+// Any registers a handler for every method under the given path, including non-standard
+// methods such as WebDAV's PROPFIND. Handlers registered for specific methods take precedence.
+// HEAD requests go to the path's GET handler if it has one, before Any.
+// It is the same as Handle(AnyMethod, path, handler).
+func Any(path string, handler HandlerType, matchers ...Matcher) {
+	Handle(AnyMethod, path, handler, matchers...)
+}
+
+// NotAcceptable registers a special handler for requests that match a route,
+// except for its Accepts matcher (406).
+// If handler is nil, a plain 406 Not Acceptable response is sent.
+func NotAcceptable(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusNotAcceptable)
+	}
+	table.notAcceptable = bless(wrap(handler))
+}
+
+// UnsupportedMediaType registers a special handler for requests that match a route,
+// except for its ContentType matcher (415).
+// If handler is nil, a plain 415 Unsupported Media Type response is sent.
+func UnsupportedMediaType(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusUnsupportedMediaType)
+	}
+	table.unsupportedMediaType = bless(wrap(handler))
 }
 
 // EnableMethodNotAllowed enables or disables automatic Method Not Allowed handling.
@@ -77,3 +110,16 @@ func Delete(path string, handler HandlerType) {
 func EnableMethodNotAllowed(enabled bool) {
 	enable405 = enabled
 }
+
+// EnableAutoOptions enables or disables automatic responses to OPTIONS requests.
+// When enabled, OPTIONS requests for paths without their own OPTIONS handler get a
+// 204 No Content response with the Allow header set. Middleware still runs, so it can add
+// other headers such as CORS headers.
+// Note that this is disabled by default.
+func EnableAutoOptions(enabled bool) {
+	if !enabled {
+		table.tree.OptionsHandler = nil
+		return
+	}
+	table.tree.OptionsHandler = table.autoOptions(bless(wrap(noContent)))
+}
@@ -21,6 +21,9 @@ var (
 	PanicHandler HandlerType
 	// LogHandler will, if set, wrap every request and be called at the very end.
 	LogHandler func(context.Context, mutil.WriterProxy, *http.Request)
+	// TimeoutHandler will, if set, be called to respond to requests that time out.
+	// By default, a plain 503 Service Unavailable response is sent. See Timeout.
+	TimeoutHandler HandlerType
 )
 
 // NotFound registers a special handler for unregistered (404) paths.
@@ -34,14 +37,16 @@ func NotFound(handler HandlerType) {
 		})
 	}
 
-	h := bless(wrap(handler))
-	routes.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+	table.notFound = wrap(handler)
+	h := bless(table.notFound)
+	table.tree.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
 		h(w, r, nil)
 	}
 }
 
 // MethodNotAllowed registers a special handler for automatically responding
 // to invalid method requests (405).
+// The Allow header is set before the handler runs.
 func MethodNotAllowed(handler HandlerType) {
 	if handler == nil {
 		handler = HandlerFunc(func(_ context.Context, w http.ResponseWriter, r *http.Request) {
@@ -52,38 +57,39 @@ func MethodNotAllowed(handler HandlerType) {
 		})
 	}
 
-	h := bless(wrap(handler))
-	routes.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
-		if !enable405 {
-			routes.NotFoundHandler(w, r)
-			return
-		}
-		h(w, r, nil)
-	}
+	table.tree.MethodNotAllowedHandler = table.methodNotAllowed(bless(wrap(handler)), &enable405)
 }
 
 // bless creates a new kamified handler using the global mux and middleware.
 func bless(h ContextHandler) httptreemux.HandlerFunc {
 	k := kami{
-		handler:      h,
-		base:         &Context,
-		autocancel:   &Cancel,
-		middleware:   defaultMW,
-		panicHandler: &PanicHandler,
-		logHandler:   &LogHandler,
+		base:           &Context,
+		autocancel:     &Cancel,
+		middleware:     defaultMW,
+		panicHandler:   &PanicHandler,
+		logHandler:     &LogHandler,
+		timeoutHandler: &TimeoutHandler,
+	}
+	k.unwrapRoute(h)
+	if _, ok := k.handler.(notFoundFallback); ok {
+		k.notFound = &table.notFound
 	}
 	return k.handle
 }
 
 // Reset changes the root Context to context.Background().
-// It removes every handler and all middleware.
+// It removes every handler, all middleware, and every health check.
 func Reset() {
 	Context = context.Background()
 	Cancel = false
 	PanicHandler = nil
 	LogHandler = nil
+	TimeoutHandler = nil
 	defaultMW = newWares()
-	routes = newRouter()
+	table = newRouteTable()
 	NotFound(nil)
 	MethodNotAllowed(nil)
+	NotAcceptable(nil)
+	UnsupportedMediaType(nil)
+	resetHealthChecks()
 }
@@ -34,14 +34,16 @@ func NotFound(handler HandlerType) {
 		})
 	}
 
-	h := bless(wrap(handler))
-	routes.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+	table.notFound = wrap(handler)
+	h := bless(table.notFound)
+	table.tree.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
 		h(w, r, nil)
 	}
 }
 
 // MethodNotAllowed registers a special handler for automatically responding
 // to invalid method requests (405).
+// The Allow header is set before the handler runs.
 func MethodNotAllowed(handler HandlerType) {
 	if handler == nil {
 		handler = HandlerFunc(func(_ context.Context, w http.ResponseWriter, r *http.Request) {
@@ -52,14 +54,7 @@ func MethodNotAllowed(handler HandlerType) {
 		})
 	}
 
-	h := bless(wrap(handler))
-	routes.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
-		if !enable405 {
-			routes.NotFoundHandler(w, r)
-			return
-		}
-		h(w, r, nil)
-	}
+	table.tree.MethodNotAllowedHandler = table.methodNotAllowed(bless(wrap(handler)), &enable405)
 }
 
 // bless creates a new kamified handler using the global mux and middleware.
@@ -83,7 +78,9 @@ func Reset() {
 	PanicHandler = nil
 	LogHandler = nil
 	defaultMW = newWares()
-	routes = newRouter()
+	table = newRouteTable()
 	NotFound(nil)
 	MethodNotAllowed(nil)
+	NotAcceptable(nil)
+	UnsupportedMediaType(nil)
 }
@@ -0,0 +1,177 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is used for health checks registered without a timeout.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// HealthCheck checks whether something the application depends on is working.
+// It returns a non-nil error if it isn't.
+// The given context is canceled when the check's timeout expires.
+type HealthCheck func(context.Context) error
+
+type healthCheck struct {
+	name    string
+	timeout time.Duration
+	check   HealthCheck
+	ready   bool // only check for readiness
+}
+
+var (
+	healthMu     sync.RWMutex
+	healthChecks []healthCheck
+)
+
+// AddHealthCheck registers a check for both Healthz and Readyz.
+// Use this for things that mean the process is broken and should be restarted.
+// If timeout is zero, DefaultHealthCheckTimeout is used.
+func AddHealthCheck(name string, timeout time.Duration, check HealthCheck) {
+	addHealthCheck(healthCheck{name: name, timeout: timeout, check: check})
+}
+
+// AddReadinessCheck registers a check for Readyz only.
+// Use this for things that mean the process shouldn't get traffic right now, such as a database outage.
+// If timeout is zero, DefaultHealthCheckTimeout is used.
+func AddReadinessCheck(name string, timeout time.Duration, check HealthCheck) {
+	addHealthCheck(healthCheck{name: name, timeout: timeout, check: check, ready: true})
+}
+
+func addHealthCheck(hc healthCheck) {
+	if hc.timeout == 0 {
+		hc.timeout = DefaultHealthCheckTimeout
+	}
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	healthChecks = append(healthChecks, hc)
+}
+
+func resetHealthChecks() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	healthChecks = nil
+}
+
+// HealthReport is the JSON response of Healthz and Readyz.
+type HealthReport struct {
+	// Status is "ok" or "fail".
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the outcome of a single HealthCheck.
+type HealthCheckResult struct {
+	Name string `json:"name"`
+	// Status is "ok" or "fail".
+	Status string `json:"status"`
+	// Error is the reason a check failed.
+	Error string `json:"error,omitempty"`
+	// Duration is how long the check took, in milliseconds.
+	Duration float64 `json:"duration_ms"`
+}
+
+// Healthz is a handler for liveness probes, usually mounted at /healthz.
+// It runs the checks registered with AddHealthCheck and responds with a JSON HealthReport,
+// with the status 200 OK if every check passed and 503 Service Unavailable otherwise.
+// 	kami.Get("/healthz", kami.Healthz)
+func Healthz(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, runHealthChecks(ctx, false))
+}
+
+// Readyz is a handler for readiness probes, usually mounted at /readyz.
+// It is like Healthz, but also runs checks registered with AddReadinessCheck.
+// As soon as a graceful shutdown begins, Readyz fails so that load balancers stop sending traffic.
+// 	kami.Get("/readyz", kami.Readyz)
+func Readyz(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	report := runHealthChecks(ctx, true)
+	if ShuttingDown() {
+		report.Status = "fail"
+		report.Checks = append(report.Checks, HealthCheckResult{
+			Name:   "shutdown",
+			Status: "fail",
+			Error:  "shutting down",
+		})
+	}
+	writeHealthReport(w, report)
+}
+
+// runHealthChecks runs the registered checks concurrently.
+func runHealthChecks(ctx context.Context, ready bool) HealthReport {
+	healthMu.RLock()
+	checks := make([]healthCheck, 0, len(healthChecks))
+	for _, hc := range healthChecks {
+		if !hc.ready || ready {
+			checks = append(checks, hc)
+		}
+	}
+	healthMu.RUnlock()
+
+	report := HealthReport{
+		Status: "ok",
+		Checks: make([]HealthCheckResult, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc healthCheck) {
+			defer wg.Done()
+			report.Checks[i] = hc.run(ctx)
+		}(i, hc)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != "ok" {
+			report.Status = "fail"
+		}
+	}
+	return report
+}
+
+// run runs this check, giving up after its timeout.
+func (hc healthCheck) run(ctx context.Context) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- hc.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthCheckResult{
+		Name:     hc.name,
+		Status:   "ok",
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == "ok" {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
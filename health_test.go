@@ -0,0 +1,76 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+func TestHealthChecks(t *testing.T) {
+	kami.Reset()
+	defer kami.Reset()
+
+	var dbDown bool
+	kami.AddHealthCheck("deadlock", 0, func(ctx context.Context) error {
+		return nil
+	})
+	kami.AddReadinessCheck("db", 0, func(ctx context.Context) error {
+		if dbDown {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	kami.AddReadinessCheck("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		if dbDown {
+			<-ctx.Done()
+		}
+		return nil
+	})
+
+	mux := kami.New()
+	mux.Get("/healthz", kami.Healthz)
+	mux.Get("/readyz", kami.Readyz)
+
+	check := func(path string, code int, failed ...string) {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Errorf("%s: got code %d, want %d", path, resp.Code, code)
+		}
+		var report kami.HealthReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, result := range report.Checks {
+			if result.Status != "ok" {
+				got = append(got, result.Name)
+			}
+		}
+		if len(got) != len(failed) {
+			t.Errorf("%s: got failed checks %v, want %v", path, got, failed)
+			return
+		}
+		for i := range got {
+			if got[i] != failed[i] {
+				t.Errorf("%s: got failed checks %v, want %v", path, got, failed)
+			}
+		}
+	}
+
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusOK)
+
+	dbDown = true
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusServiceUnavailable, "db", "slow")
+}
@@ -0,0 +1,154 @@
+// +build go1.7
+
+package kami
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+type hostParamsKey struct{}
+
+// Host returns a mux that handles requests for the given host name, before path routing happens.
+// The pattern may be an exact name such as "api.example.com", or have named parameters
+// for whole labels such as ":tenant.example.com", which you can access with kami.Param(ctx, "tenant").
+// Exact names are matched before patterns with parameters, which are tried in order of registration.
+// Requests for hosts that don't match any pattern are handled by this mux as usual.
+//
+// The host mux has its own routes and middleware. Middleware and afterware registered on this mux
+// still run for requests to the host mux, before the host's own middleware and after its own afterware.
+// Context, Cancel, PanicHandler, LogHandler, and TimeoutHandler are taken from this mux.
+// The NotFound, MethodNotAllowed, EnableMethodNotAllowed, and EnableAutoOptions settings
+// follow this mux's, even if they change later, unless they're set on the host mux itself.
+// Calling Host again with the same pattern returns the same mux.
+func (m *Mux) Host(pattern string) *Mux {
+	if m.parent != nil {
+		panic("kami: Host can't be used on a host mux")
+	}
+	if m.hosts == nil {
+		m.hosts = new(hostRouter)
+	}
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if host := m.hosts.get(pattern); host != nil {
+		return host
+	}
+
+	host := newMux(m)
+	m.hosts.add(pattern, host)
+	return host
+}
+
+// ownSettings records which of its parent's settings a host mux has overridden.
+type ownSettings struct {
+	notFound, methodNotAllowed, enable405, autoOptions bool
+}
+
+// eachHost calls f with each of this mux's host muxes.
+func (m *Mux) eachHost(f func(*Mux)) {
+	if m.hosts == nil {
+		return
+	}
+	for _, host := range m.hosts.exact {
+		f(host)
+	}
+	for _, hp := range m.hosts.patterns {
+		f(hp.mux)
+	}
+}
+
+// hostRouter picks a mux based on the Host header.
+type hostRouter struct {
+	exact    map[string]*Mux
+	patterns []hostPattern
+}
+
+type hostPattern struct {
+	pattern string
+	labels  []string
+	mux     *Mux
+}
+
+func (hr *hostRouter) add(pattern string, mux *Mux) {
+	if !strings.Contains(pattern, ":") {
+		if hr.exact == nil {
+			hr.exact = make(map[string]*Mux)
+		}
+		hr.exact[pattern] = mux
+		return
+	}
+	hr.patterns = append(hr.patterns, hostPattern{
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+		mux:     mux,
+	})
+}
+
+func (hr *hostRouter) get(pattern string) *Mux {
+	if mux, ok := hr.exact[pattern]; ok {
+		return mux
+	}
+	for _, hp := range hr.patterns {
+		if hp.pattern == pattern {
+			return hp.mux
+		}
+	}
+	return nil
+}
+
+// match finds the mux for a Host header, returning any named parameters.
+func (hr *hostRouter) match(host string) (*Mux, map[string]string) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if mux, ok := hr.exact[host]; ok {
+		return mux, nil
+	}
+	if len(hr.patterns) == 0 {
+		return nil, nil
+	}
+
+	labels := strings.Split(host, ".")
+next:
+	for _, hp := range hr.patterns {
+		if len(hp.labels) != len(labels) {
+			continue
+		}
+		var params map[string]string
+		for i, label := range hp.labels {
+			if strings.HasPrefix(label, ":") {
+				if labels[i] == "" {
+					continue next
+				}
+				if params == nil {
+					params = make(map[string]string)
+				}
+				params[label[1:]] = labels[i]
+				continue
+			}
+			if label != labels[i] {
+				continue next
+			}
+		}
+		return hp.mux, params
+	}
+	return nil, nil
+}
+
+// withHostParams adds the host parameters found by Mux.ServeHTTP to the path parameters.
+func withHostParams(r *http.Request, params map[string]string) map[string]string {
+	hostParams, _ := r.Context().Value(hostParamsKey{}).(map[string]string)
+	if len(hostParams) == 0 {
+		return params
+	}
+	merged := make(map[string]string, len(hostParams)+len(params))
+	for k, v := range hostParams {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,120 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestHostRouting(t *testing.T) {
+	mux := kami.New()
+	mux.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		return context.WithValue(ctx, "shared", true)
+	})
+	mux.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "default")
+	})
+
+	api := mux.Host("api.example.com")
+	api.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "api")
+	})
+
+	tenants := mux.Host(":tenant.example.com")
+	tenants.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if ctx.Value("shared") == nil {
+			t.Error("shared middleware should run before host middleware")
+		}
+		if kami.Param(ctx, "tenant") == "banned" {
+			w.WriteHeader(http.StatusForbidden)
+			return nil
+		}
+		return ctx
+	})
+	tenants.Get("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, kami.Param(ctx, "tenant")+" "+kami.Param(ctx, "id"))
+	})
+
+	if mux.Host("API.example.com.") != api {
+		t.Error("Host should return the same mux for the same host")
+	}
+
+	expect := func(host, path string, code int, body string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Host = host
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code || resp.Body.String() != body {
+			t.Errorf("%s%s: got %d %q, want %d %q", host, path, resp.Code, resp.Body.String(), code, body)
+		}
+	}
+
+	expect("example.com", "/", http.StatusOK, "default")
+	expect("api.example.com", "/", http.StatusOK, "api")
+	expect("API.EXAMPLE.COM:8080", "/", http.StatusOK, "api")
+	expect("acme.example.com", "/users/42", http.StatusOK, "acme 42")
+	expect("banned.example.com", "/users/42", http.StatusForbidden, "")
+	expect("acme.example.com", "/", http.StatusNotFound, "404 page not found\n")
+	expect("a.b.example.com", "/", http.StatusOK, "default")
+}
+
+func TestHostInheritsSettings(t *testing.T) {
+	text := func(s string) kami.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, s)
+		}
+	}
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+
+	mux := kami.New()
+	api := mux.Host("api.example.com")
+	api.Get("/thing", noop)
+	admin := mux.Host("admin.example.com")
+	admin.Get("/thing", noop)
+
+	// set on the parent after the hosts were made
+	mux.NotFound(text("parent 404"))
+	mux.MethodNotAllowed(text("parent 405"))
+	mux.EnableAutoOptions(true)
+
+	// admin has its own
+	admin.NotFound(text("admin 404"))
+	admin.EnableMethodNotAllowed(false)
+	admin.EnableAutoOptions(false)
+
+	expect := func(method, host, path string, code int, body string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = host
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code || (body != "" && resp.Body.String() != body) {
+			t.Errorf("%s %s%s: got %d %q, want %d %q", method, host, path, resp.Code, resp.Body.String(), code, body)
+		}
+	}
+	expect("GET", "api.example.com", "/missing", http.StatusOK, "parent 404")
+	expect("POST", "api.example.com", "/thing", http.StatusOK, "parent 405")
+	expect("OPTIONS", "api.example.com", "/thing", http.StatusNoContent, "")
+	expect("GET", "admin.example.com", "/missing", http.StatusOK, "admin 404")
+	expect("POST", "admin.example.com", "/thing", http.StatusOK, "admin 404")
+	expect("OPTIONS", "admin.example.com", "/thing", http.StatusOK, "admin 404")
+
+	// nil goes back to the parent's
+	admin.NotFound(nil)
+	mux.NotFound(text("new 404"))
+	expect("GET", "admin.example.com", "/missing", http.StatusOK, "new 404")
+	expect("GET", "api.example.com", "/missing", http.StatusOK, "new 404")
+
+	// new hosts start with the parent's settings
+	expect("POST", "www.example.com", "/missing", http.StatusOK, "new 404")
+	www := mux.Host("www.example.com")
+	www.Get("/thing", noop)
+	expect("POST", "www.example.com", "/thing", http.StatusOK, "parent 405")
+	expect("OPTIONS", "www.example.com", "/thing", http.StatusNoContent, "")
+}
@@ -4,6 +4,7 @@ package kami
 
 import (
 	"net/http"
+	"runtime/debug"
 
 	"github.com/zenazn/goji/web/mutil"
 	"golang.org/x/net/context"
@@ -34,6 +35,9 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 	if len(params) > 0 {
 		ctx = newContextWithParams(ctx, params)
 	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		ctx = newContextWithClientCert(ctx, r.TLS.VerifiedChains[0])
+	}
 
 	if autocancel {
 		var cancel context.CancelFunc
@@ -50,7 +54,7 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 	if panicHandler != nil {
 		defer func() {
 			if err := recover(); err != nil {
-				ctx = newContextWithException(ctx, err)
+				ctx = newContextWithException(ctx, err, debug.Stack())
 				wrap(panicHandler).ServeHTTPContext(ctx, w, r)
 
 				if logHandler != nil && !ranLogHandler {
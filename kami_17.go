@@ -5,6 +5,8 @@ package kami
 import (
 	"context"
 	"net/http"
+	"runtime/debug"
+	"time"
 
 	"github.com/zenazn/goji/web/mutil"
 )
@@ -17,23 +19,54 @@ type kami struct {
 	autocancel   *bool
 	base         *context.Context
 	middleware   *wares
+	shared       *wares // parent middleware for host muxes, run before middleware
 	panicHandler *HandlerType
 	logHandler   *func(context.Context, mutil.WriterProxy, *http.Request)
+	notFound     *ContextHandler // for handlers that fall back to NotFound, see notFoundFallback
+
+	timeout        time.Duration // the route's own timeout, see WithTimeout
+	timeoutHandler *HandlerType
+	route          *Route
+}
+
+// notFoundFallback is implemented by handlers that call the NotFound handler for misses,
+// such as FileServer. Only their requests carry it in the context.
+type notFoundFallback interface {
+	fallsBackToNotFound()
 }
 
+type notFoundKey struct{}
+
 func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var (
 		ctx           = defaultContext(*k.base, r)
 		autocancel    = *k.autocancel
-		handler       = k.handler
 		mw            = *k.middleware
 		panicHandler  = *k.panicHandler
 		logHandler    = *k.logHandler
 		ranLogHandler = false // track this in case the log handler blows up
 	)
+	if k.shared != nil {
+		params = withHostParams(r, params)
+	}
 	if len(params) > 0 {
 		ctx = newContextWithParams(ctx, params)
 	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		ctx = newContextWithClientCert(ctx, r.TLS.VerifiedChains[0])
+	}
+	if addr := proxySource(r); addr != nil {
+		ctx = newContextWithProxyAddr(ctx, addr)
+	}
+	if methods, ok := r.Context().Value(allowedMethodsKey{}).([]string); ok {
+		ctx = context.WithValue(ctx, allowedMethodsKey{}, methods)
+	}
+	if k.route != nil {
+		ctx = context.WithValue(ctx, routeKey{}, k.route)
+	}
+	if k.notFound != nil {
+		ctx = context.WithValue(ctx, notFoundKey{}, *k.notFound)
+	}
 
 	if autocancel {
 		var cancel context.CancelFunc
@@ -46,7 +79,7 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 	}
 
 	var proxy mutil.WriterProxy
-	if logHandler != nil || mw.needsWrapper() {
+	if logHandler != nil || mw.needsWrapper() || (k.shared != nil && k.shared.needsWrapper()) {
 		proxy = mutil.WrapWriter(w)
 		w = proxy
 	}
@@ -54,7 +87,12 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 	if panicHandler != nil {
 		defer func() {
 			if err := recover(); err != nil {
-				ctx = newContextWithException(ctx, err)
+				stack := debug.Stack()
+				if p, ok := err.(*handlerPanic); ok {
+					// it happened in runTimeout's goroutine
+					err, stack = p.value, p.stack
+				}
+				ctx = newContextWithException(ctx, err, stack)
 				r = r.WithContext(ctx)
 				wrap(panicHandler).ServeHTTPContext(ctx, w, r)
 
@@ -67,12 +105,36 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 		}()
 	}
 
-	r, ctx, ok := mw.run(ctx, w, r)
+	ok := true
+	if k.shared != nil && k.shared.limiters != nil {
+		var release func()
+		release, ctx, ok = k.shared.limit(ctx, w, r)
+		defer release()
+	}
+	if ok && mw.limiters != nil {
+		var release func()
+		release, ctx, ok = mw.limit(ctx, w, r)
+		defer release()
+	}
+	if !ok {
+		r = r.WithContext(ctx)
+	}
+
 	if ok {
-		handler.ServeHTTPContext(ctx, w, r)
+		if timeout := k.timeoutFor(&mw, r); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+			r, ctx = k.runTimeout(ctx, w, r.WithContext(ctx), &mw)
+		} else {
+			r, ctx = k.run(ctx, w, r, &mw)
+		}
 	}
 	if proxy != nil {
 		r, ctx = mw.after(ctx, proxy, r)
+		if k.shared != nil {
+			r, ctx = k.shared.after(ctx, proxy, r)
+		}
 	}
 
 	if logHandler != nil {
@@ -82,3 +144,18 @@ func (k kami) handle(w http.ResponseWriter, r *http.Request, params map[string]s
 		proxy.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// run runs middleware and then the handler, unless middleware stops the request.
+func (k kami) run(ctx context.Context, w http.ResponseWriter, r *http.Request, mw *wares) (*http.Request, context.Context) {
+	ok := true
+	if k.shared != nil {
+		r, ctx, ok = k.shared.run(ctx, w, r)
+	}
+	if ok {
+		r, ctx, ok = mw.run(ctx, w, r)
+	}
+	if ok {
+		k.handler.ServeHTTPContext(ctx, w, r)
+	}
+	return r, ctx
+}
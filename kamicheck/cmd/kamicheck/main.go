@@ -0,0 +1,15 @@
+// Command kamicheck checks how handlers and middleware are registered with kami.
+// Run it with go vet:
+//
+//	go vet -vettool=$(which kamicheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/guregu/kami/kamicheck"
+)
+
+func main() {
+	unitchecker.Main(kamicheck.Analyzer)
+}
@@ -0,0 +1,326 @@
+// Package kamicheck is an analyzer that checks how handlers and middleware are registered with kami.
+//
+// It reports:
+//   - handlers, middleware, and afterware of types that kami doesn't accept,
+//     which would otherwise panic when the program starts,
+//     or be rejected by LoadConfig if they were given to Register
+//   - routes registered twice, or with wildcards that conflict with another route,
+//     including routes registered with the global router by different packages
+//   - middleware that halts a request by returning nil without writing a response
+//
+// Use it with go vet:
+//
+//	go install github.com/guregu/kami/kamicheck/cmd/kamicheck
+//	go vet -vettool=$(which kamicheck) ./...
+package kamicheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const kamiPath = "github.com/guregu/kami"
+
+// Analyzer checks kami registrations.
+var Analyzer = &analysis.Analyzer{
+	Name:      "kamicheck",
+	Doc:       "check handlers, middleware, and routes registered with kami",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(routesFact)},
+}
+
+// routesFact lists the routes a package registers with the global router.
+type routesFact struct {
+	Routes []Route
+}
+
+func (*routesFact) AFact() {}
+
+func (f *routesFact) String() string {
+	return fmt.Sprintf("%d routes", len(f.Routes))
+}
+
+// Route is a route registered with a constant method and path.
+type Route struct {
+	Method string
+	Path   string
+	// Matchers is true if the route was registered with matchers,
+	// which allow several handlers for the same method and path.
+	Matchers bool
+	// Pos is where the route was registered.
+	Pos token.Position
+
+	pos token.Pos // only for routes in the package being checked
+}
+
+// argument kinds
+const (
+	handlerArg = iota
+	middlewareArg
+	afterwareArg
+	specialHandlerArg // NotFound etc.: like a handler, but nil is OK
+	registeredArg     // Register: a handler, middleware, or afterware for LoadConfig
+)
+
+type registration struct {
+	arg    int  // index of the handler or middleware argument
+	kind   int  // what kind of argument it is
+	route  bool // registers a route that can conflict with others
+	method string
+}
+
+var registrations = map[string]registration{
+	"Handle":               {arg: 2, kind: handlerArg, route: true},
+	"TryHandle":            {arg: 2, kind: handlerArg, route: true},
+	"Replace":              {arg: 2, kind: handlerArg},
+	"Get":                  {arg: 1, kind: handlerArg, route: true, method: "GET"},
+	"Post":                 {arg: 1, kind: handlerArg, route: true, method: "POST"},
+	"Put":                  {arg: 1, kind: handlerArg, route: true, method: "PUT"},
+	"Patch":                {arg: 1, kind: handlerArg, route: true, method: "PATCH"},
+	"Head":                 {arg: 1, kind: handlerArg, route: true, method: "HEAD"},
+	"Options":              {arg: 1, kind: handlerArg, route: true, method: "OPTIONS"},
+	"Delete":               {arg: 1, kind: handlerArg, route: true, method: "DELETE"},
+	"Any":                  {arg: 1, kind: handlerArg, route: true, method: "*"},
+	"NotFound":             {arg: 0, kind: specialHandlerArg},
+	"MethodNotAllowed":     {arg: 0, kind: specialHandlerArg},
+	"NotAcceptable":        {arg: 0, kind: specialHandlerArg},
+	"UnsupportedMediaType": {arg: 0, kind: specialHandlerArg},
+	"Use":                  {arg: 1, kind: middlewareArg},
+	"TryUse":               {arg: 1, kind: middlewareArg},
+	"After":                {arg: 1, kind: afterwareArg},
+	"TryAfter":             {arg: 1, kind: afterwareArg},
+	"Register":             {arg: 1, kind: registeredArg},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if pass.Pkg.Path() == kamiPath {
+		return nil, nil
+	}
+	kami := findKami(pass.Pkg)
+	if kami == nil {
+		return nil, nil
+	}
+	k := newKamiTypes(kami)
+	if k == nil {
+		return nil, nil
+	}
+
+	// routes registered in this package, by router: nil for the global router
+	routes := make(map[types.Object][]Route)
+	var routers []types.Object
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, _ := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+			if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != kamiPath {
+				return true
+			}
+			router, global, ok := receiver(pass, fn, call)
+			if !ok {
+				return true
+			}
+			reg, ok := registrations[fn.Name()]
+			if !ok || len(call.Args) <= reg.arg {
+				return true
+			}
+
+			arg := call.Args[reg.arg]
+			k.checkArg(pass, reg.kind, arg)
+			if reg.kind == middlewareArg {
+				k.checkNilMiddleware(pass, arg)
+			}
+
+			if reg.route && (global || router != nil) {
+				if rt, ok := constantRoute(pass, call, reg); ok {
+					if _, seen := routes[router]; !seen {
+						routers = append(routers, router)
+					}
+					routes[router] = append(routes[router], rt)
+				}
+			}
+			return true
+		})
+	}
+
+	// compare with routes registered with the global router by dependencies
+	var deps []Route
+	for _, fact := range pass.AllPackageFacts() {
+		if f, ok := fact.Fact.(*routesFact); ok && fact.Package != pass.Pkg {
+			deps = append(deps, f.Routes...)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return posLess(deps[i].Pos, deps[j].Pos) })
+	if pass.Pkg.Name() == "main" && len(pass.Files) > 0 {
+		// only programs bring unrelated packages together
+		for i, rt := range deps {
+			for _, prev := range deps[:i] {
+				if msg := conflict(prev, rt); msg != "" {
+					pass.Reportf(pass.Files[0].Package, "route %s %s (%s) %s %s", rt.Method, rt.Path, rt.Pos, msg, prev.Pos)
+				}
+			}
+		}
+	}
+
+	for _, router := range routers {
+		var prior []Route
+		if router == nil {
+			prior = deps
+		}
+		for _, rt := range routes[router] {
+			for _, prev := range prior {
+				if msg := conflict(prev, rt); msg != "" {
+					pass.Reportf(rt.pos, "route %s %s %s %s", rt.Method, rt.Path, msg, prev.Pos)
+					break
+				}
+			}
+			prior = append(prior, rt)
+		}
+	}
+
+	if global := routes[nil]; len(global) > 0 {
+		pass.ExportPackageFact(&routesFact{Routes: global})
+	}
+	return nil, nil
+}
+
+// findKami finds kami among a package's imports.
+func findKami(pkg *types.Package) *types.Package {
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == kamiPath {
+			return imp
+		}
+	}
+	return nil
+}
+
+// receiver finds the router a registration function is called on.
+// The global router is reported as global, while a mux is reported as the variable holding it.
+// Routes for other muxes, such as those returned by functions, can't be tracked.
+func receiver(pass *analysis.Pass, fn *types.Func, call *ast.CallExpr) (router types.Object, global, ok bool) {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil, true, true
+	}
+	t := recv.Type()
+	if ptr, isPtr := t.(*types.Pointer); isPtr {
+		t = ptr.Elem()
+	}
+	named, isNamed := t.(*types.Named)
+	if !isNamed {
+		return nil, false, false
+	}
+	switch named.Obj().Name() {
+	case "Mux", "wares":
+	case "RouteUpdate":
+		return nil, false, true
+	default:
+		return nil, false, false
+	}
+	if sel, isSel := call.Fun.(*ast.SelectorExpr); isSel {
+		if id, isIdent := sel.X.(*ast.Ident); isIdent {
+			if obj, isVar := pass.TypesInfo.Uses[id].(*types.Var); isVar {
+				return obj, false, true
+			}
+		}
+	}
+	return nil, false, true
+}
+
+// constantRoute returns the route registered by call, if its method and path are constants.
+func constantRoute(pass *analysis.Pass, call *ast.CallExpr, reg registration) (Route, bool) {
+	method := reg.method
+	pathArg := 0
+	if method == "" {
+		var ok bool
+		if method, ok = constantString(pass, call.Args[0]); !ok {
+			return Route{}, false
+		}
+		pathArg = 1
+	}
+	path, ok := constantString(pass, call.Args[pathArg])
+	if !ok {
+		return Route{}, false
+	}
+	return Route{
+		Method:   method,
+		Path:     path,
+		Matchers: len(call.Args) > reg.arg+1 || call.Ellipsis.IsValid(),
+		Pos:      pass.Fset.Position(call.Pos()),
+		pos:      call.Pos(),
+	}, true
+}
+
+// posLess orders positions by file name, then line and column.
+func posLess(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+func constantString(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// conflict explains why a route can't be registered after prev, or returns "".
+func conflict(prev, rt Route) string {
+	if routeShape(prev.Path) != routeShape(rt.Path) {
+		return ""
+	}
+	if !reflect.DeepEqual(wildcards(prev.Path), wildcards(rt.Path)) {
+		return "has wildcards that conflict with the route registered at"
+	}
+	if prev.Method != rt.Method {
+		return ""
+	}
+	if prev.Path != rt.Path {
+		return "only differs by a trailing slash from the route registered at"
+	}
+	if !prev.Matchers && !rt.Matchers {
+		return "is already registered at"
+	}
+	return ""
+}
+
+// routeShape blanks out the wildcard names of a path, and its trailing slash.
+func routeShape(path string) string {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = ":"
+		} else if strings.HasPrefix(part, "*") {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// wildcards returns the names of a path's wildcards.
+func wildcards(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			names = append(names, part)
+		}
+	}
+	return names
+}
@@ -0,0 +1,13 @@
+package kamicheck_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/guregu/kami/kamicheck"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), kamicheck.Analyzer, "a", "app")
+}
@@ -0,0 +1,119 @@
+package kamicheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// checkNilMiddleware reports middleware that halts requests by returning nil
+// before anything could have written a response.
+// It only looks at function literals and functions declared in the same package.
+func (k *kamiTypes) checkNilMiddleware(pass *analysis.Pass, arg ast.Expr) {
+	var ftype *ast.FuncType
+	var body *ast.BlockStmt
+	switch x := astutil.Unparen(arg).(type) {
+	case *ast.FuncLit:
+		ftype, body = x.Type, x.Body
+	case *ast.Ident:
+		fn, ok := pass.TypesInfo.Uses[x].(*types.Func)
+		if !ok {
+			return
+		}
+		decl := findFuncDecl(pass, fn)
+		if decl == nil {
+			return
+		}
+		ftype, body = decl.Type, decl.Body
+	case *ast.CallExpr:
+		// kami.NewMiddleware(fn)
+		if fn, _ := typeutil.Callee(pass.TypesInfo, x).(*types.Func); fn != nil && fn.Pkg() != nil &&
+			fn.Pkg().Path() == kamiPath && fn.Name() == "NewMiddleware" && len(x.Args) == 1 {
+			k.checkNilMiddleware(pass, x.Args[0])
+		}
+		return
+	default:
+		return
+	}
+	if body == nil || ftype.Results == nil || len(ftype.Results.List) != 1 {
+		return
+	}
+
+	// find the http.ResponseWriter parameter
+	var w types.Object
+	hasWriter := false
+	for _, field := range ftype.Params.List {
+		if !types.Identical(pass.TypesInfo.TypeOf(field.Type), k.w) {
+			continue
+		}
+		hasWriter = true
+		for _, name := range field.Names {
+			if obj := pass.TypesInfo.Defs[name]; obj != nil && name.Name != "_" {
+				w = obj
+			}
+		}
+	}
+	if !hasWriter {
+		return
+	}
+
+	var writes []token.Pos
+	var returns []*ast.ReturnStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(x.Results) == 1 && pass.TypesInfo.Types[x.Results[0]].IsNil() {
+				returns = append(returns, x)
+			}
+		case *ast.CallExpr:
+			if w == nil {
+				break
+			}
+			if sel, ok := x.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name != "Header" && uses(pass, sel.X, w) {
+				writes = append(writes, x.Pos())
+			}
+			for _, a := range x.Args {
+				if uses(pass, a, w) {
+					writes = append(writes, x.Pos())
+				}
+			}
+		}
+		return true
+	})
+
+	for _, ret := range returns {
+		written := false
+		for _, pos := range writes {
+			if pos < ret.Pos() {
+				written = true
+				break
+			}
+		}
+		if !written {
+			pass.Reportf(ret.Pos(), "middleware returns nil without writing a response")
+		}
+	}
+}
+
+// uses returns true if expr is the variable obj.
+func uses(pass *analysis.Pass, expr ast.Expr, obj types.Object) bool {
+	id, ok := astutil.Unparen(expr).(*ast.Ident)
+	return ok && pass.TypesInfo.Uses[id] == obj
+}
+
+func findFuncDecl(pass *analysis.Pass, fn *types.Func) *ast.FuncDecl {
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && pass.TypesInfo.Defs[fd.Name] == fn {
+				return fd
+			}
+		}
+	}
+	return nil
+}
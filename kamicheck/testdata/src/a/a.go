@@ -0,0 +1,86 @@
+package a // want package:"14 routes"
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/guregu/kami"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+func show(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+
+func auth(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	if r.Header.Get("Authorization") == "" {
+		return nil // want "middleware returns nil without writing a response"
+	}
+	return ctx
+}
+
+func login(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	if r.Header.Get("Authorization") == "" {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	return ctx
+}
+
+func types() {
+	kami.Get("/a", show)
+	kami.Get("/b", http.NotFound)
+	kami.Get("/c", kami.HandlerFunc(show))
+	kami.Get("/d", func(w http.ResponseWriter) {}) // want `unsupported handler type func\(w net/http.ResponseWriter\) will panic`
+	kami.Get("/e", "hello")                        // want "unsupported handler type string will panic"
+	kami.Get("/f", nil)                            // want "nil handler will panic"
+	kami.NotFound(nil)
+	kami.Use("/", auth)
+	kami.Use("/", login)
+	kami.Use("/", func(next http.Handler) http.Handler { return next })
+	kami.Use("/", func(ctx context.Context) context.Context { return ctx }) // want `unsupported middleware type func\(ctx context.Context\) context.Context will panic`
+	kami.After("/", func(ctx context.Context, w mutil.WriterProxy, r *http.Request) context.Context { return ctx })
+	kami.After("/", func(ctx context.Context) context.Context { return ctx })
+	kami.After("/", func() {}) // want `unsupported afterware type func\(\) will panic`
+	kami.Register("show", show)
+	kami.Register("auth", auth)
+	kami.Register("after", func(ctx context.Context) context.Context { return ctx })
+	kami.Register("bogus", 42) // want "unsupported handler, middleware, or afterware type int will be rejected by LoadConfig"
+	kami.Register("nil", nil)  // want "nil handler, middleware, or afterware will be rejected by LoadConfig"
+}
+
+func nilMiddleware() {
+	mux := kami.New()
+	mux.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		io.WriteString(w, "hi")
+		return nil
+	})
+	mux.Use("/", func(ctx context.Context, _ http.ResponseWriter, r *http.Request) context.Context {
+		return nil // want "middleware returns nil without writing a response"
+	})
+	mux.Use("/", kami.NewMiddleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		return nil // want "middleware returns nil without writing a response"
+	}))
+}
+
+func routes() {
+	kami.Get("/users/:id", show)
+	kami.Get("/users/:id", show) // want `route GET /users/:id is already registered at .*a.go:\d+:\d+`
+	kami.Post("/users/:id", show)
+	kami.Post("/users/:name", show) // want `route POST /users/:name has wildcards that conflict with the route registered at`
+	kami.Get("/users/", show)
+	kami.Get("/users", show) // want `route GET /users only differs by a trailing slash from the route registered at`
+	kami.Get("/accept", show)
+	kami.Get("/accept", show, kami.MatchFunc(func(r *http.Request) bool { return true }))
+
+	// each mux has its own routes
+	mux := kami.New()
+	mux.Get("/users/:id", show)
+	mux.Get("/users/:id", show) // want `route GET /users/:id is already registered at`
+	other := kami.New()
+	other.Get("/users/:id", show)
+
+	path := "/dynamic"
+	kami.Get(path, show)
+	kami.Get(path, show)
+}
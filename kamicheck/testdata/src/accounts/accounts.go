@@ -0,0 +1,14 @@
+package accounts
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/guregu/kami"
+)
+
+func show(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+
+func init() {
+	kami.Get("/users/:id", show)
+}
@@ -0,0 +1,18 @@
+package main // want package:"2 routes" `route POST /users/:name \(.*posts.go:\d+:\d+\) has wildcards that conflict with the route registered at .*accounts.go:\d+:\d+`
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/guregu/kami"
+
+	_ "accounts"
+	_ "posts"
+)
+
+func home(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+
+func main() {
+	kami.Get("/", home)
+	kami.Get("/users/:id", home) // want `route GET /users/:id is already registered at .*accounts.go:\d+:\d+`
+}
@@ -0,0 +1,56 @@
+// Package kami is a stub of the parts of kami that kamicheck looks at.
+package kami
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+type ContextHandler interface {
+	ServeHTTPContext(context.Context, http.ResponseWriter, *http.Request)
+}
+
+type HandlerFunc func(context.Context, http.ResponseWriter, *http.Request)
+
+func (h HandlerFunc) ServeHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	h(ctx, w, r)
+}
+
+type HandlerType interface{}
+type MiddlewareType interface{}
+type AfterwareType interface{}
+
+type Middleware func(context.Context, http.ResponseWriter, *http.Request) context.Context
+type Afterware func(context.Context, mutil.WriterProxy, *http.Request) context.Context
+
+type Matcher struct {
+	match  func(*http.Request) bool
+	status int
+}
+
+func MatchFunc(f func(*http.Request) bool) Matcher { return Matcher{match: f} }
+
+type Mux struct{ *wares }
+
+type wares struct{}
+
+func New() *Mux { return &Mux{wares: &wares{}} }
+
+func (m *Mux) Handle(method, path string, handler HandlerType, matchers ...Matcher) {}
+func (m *Mux) Get(path string, handler HandlerType, matchers ...Matcher)            {}
+func (m *Mux) Post(path string, handler HandlerType, matchers ...Matcher)           {}
+func (m *Mux) NotFound(handler HandlerType)                                         {}
+func (m *wares) Use(path string, mw MiddlewareType)                                 {}
+func (m *wares) After(path string, aw AfterwareType)                                {}
+
+func Handle(method, path string, handler HandlerType, matchers ...Matcher) {}
+func Get(path string, handler HandlerType, matchers ...Matcher)            {}
+func Post(path string, handler HandlerType, matchers ...Matcher)           {}
+func NotFound(handler HandlerType)                                         {}
+func Use(path string, mw MiddlewareType)                                   {}
+func After(path string, aw AfterwareType)                                  {}
+func Register(name string, handlerOrMiddleware interface{})                {}
+
+func NewMiddleware[F any](f F) Middleware { return nil }
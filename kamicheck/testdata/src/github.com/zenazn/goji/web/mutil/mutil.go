@@ -0,0 +1,8 @@
+package mutil
+
+import "net/http"
+
+type WriterProxy interface {
+	http.ResponseWriter
+	Status() int
+}
@@ -0,0 +1,175 @@
+package kamicheck
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// kamiTypes holds the types that kami converts handlers and middleware from.
+type kamiTypes struct {
+	middleware     types.Type
+	afterware      types.Type
+	contextHandler *types.Interface
+	httpHandler    *types.Interface
+
+	contextHandlerT, httpHandlerT types.Type // the named interfaces
+
+	ctx, w, r, wp types.Type
+}
+
+func newKamiTypes(kami *types.Package) *kamiTypes {
+	lookup := func(name string) types.Type {
+		obj, _ := kami.Scope().Lookup(name).(*types.TypeName)
+		if obj == nil {
+			return nil
+		}
+		return obj.Type()
+	}
+	k := &kamiTypes{
+		middleware: lookup("Middleware"),
+		afterware:  lookup("Afterware"),
+	}
+	ch := lookup("ContextHandler")
+	if k.middleware == nil || k.afterware == nil || ch == nil {
+		return nil
+	}
+	k.contextHandlerT = ch
+	k.contextHandler, _ = ch.Underlying().(*types.Interface)
+
+	// find the standard types from kami's own signatures
+	mw, _ := k.middleware.Underlying().(*types.Signature)
+	aw, _ := k.afterware.Underlying().(*types.Signature)
+	if mw == nil || aw == nil || mw.Params().Len() != 3 || aw.Params().Len() != 3 {
+		return nil
+	}
+	k.ctx = mw.Params().At(0).Type()
+	k.w = mw.Params().At(1).Type()
+	k.r = mw.Params().At(2).Type()
+	k.wp = aw.Params().At(1).Type()
+
+	w, _ := k.w.(*types.Named)
+	if w == nil || k.contextHandler == nil {
+		return nil
+	}
+	handler, _ := w.Obj().Pkg().Scope().Lookup("Handler").(*types.TypeName)
+	if handler == nil {
+		return nil
+	}
+	k.httpHandlerT = handler.Type()
+	k.httpHandler, _ = handler.Type().Underlying().(*types.Interface)
+	if k.httpHandler == nil {
+		return nil
+	}
+	return k
+}
+
+// checkArg reports an argument of a type that kami doesn't accept.
+func (k *kamiTypes) checkArg(pass *analysis.Pass, kind int, arg ast.Expr) {
+	tv, ok := pass.TypesInfo.Types[arg]
+	if !ok {
+		return
+	}
+	t := tv.Type
+	if tv.IsNil() {
+		if kind != specialHandlerArg {
+			pass.Reportf(arg.Pos(), "nil %s %s", kindName(kind), outcome(kind))
+		}
+		return
+	}
+
+	var accepted bool
+	switch kind {
+	case handlerArg, specialHandlerArg:
+		accepted = k.isHandler(t)
+	case middlewareArg:
+		accepted = k.isMiddleware(t)
+	case afterwareArg:
+		accepted = k.isAfterware(t)
+	case registeredArg:
+		accepted = k.isHandler(t) || k.isMiddleware(t) || k.isAfterware(t)
+	}
+	if accepted {
+		return
+	}
+	if types.IsInterface(t) {
+		// could be anything at run time
+		return
+	}
+	pass.Reportf(arg.Pos(), "unsupported %s type %s %s", kindName(kind), types.TypeString(t, types.RelativeTo(pass.Pkg)), outcome(kind))
+}
+
+func kindName(kind int) string {
+	switch kind {
+	case middlewareArg:
+		return "middleware"
+	case afterwareArg:
+		return "afterware"
+	case registeredArg:
+		return "handler, middleware, or afterware"
+	}
+	return "handler"
+}
+
+// outcome says what happens to an argument that kami doesn't accept.
+func outcome(kind int) string {
+	if kind == registeredArg {
+		return "will be rejected by LoadConfig"
+	}
+	return "will panic"
+}
+
+// These follow the type switches in wrap, convert, and convertAW.
+
+func (k *kamiTypes) isHandler(t types.Type) bool {
+	return types.Implements(t, k.contextHandler) ||
+		types.Implements(t, k.httpHandler) ||
+		k.isFunc(t, []types.Type{k.ctx, k.w, k.r}, nil) ||
+		k.isFunc(t, []types.Type{k.w, k.r}, nil)
+}
+
+func (k *kamiTypes) isMiddleware(t types.Type) bool {
+	return types.Identical(t, k.middleware) ||
+		k.isFunc(t, []types.Type{k.ctx, k.w, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.w, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.httpHandlerT}, []types.Type{k.httpHandlerT}) ||
+		k.isFunc(t, []types.Type{k.contextHandlerT}, []types.Type{k.contextHandlerT}) ||
+		types.Implements(t, k.httpHandler) ||
+		k.isFunc(t, []types.Type{k.w, k.r}, nil)
+}
+
+func (k *kamiTypes) isAfterware(t types.Type) bool {
+	return types.Identical(t, k.afterware) ||
+		types.Identical(t, k.middleware) ||
+		k.isFunc(t, []types.Type{k.ctx, k.wp, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.ctx, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.ctx}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.ctx, k.w, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.w, k.r}, []types.Type{k.ctx}) ||
+		k.isFunc(t, []types.Type{k.wp, k.r}, []types.Type{k.ctx}) ||
+		types.Implements(t, k.httpHandler) ||
+		k.isFunc(t, []types.Type{k.w, k.r}, nil) ||
+		k.isFunc(t, []types.Type{k.wp, k.r}, nil)
+}
+
+// isFunc returns true if t is an unnamed function type with the given parameters and results.
+func (k *kamiTypes) isFunc(t types.Type, params, results []types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	if !ok || sig.Variadic() {
+		return false
+	}
+	return tupleIs(sig.Params(), params) && tupleIs(sig.Results(), results)
+}
+
+func tupleIs(tuple *types.Tuple, want []types.Type) bool {
+	if tuple.Len() != len(want) {
+		return false
+	}
+	for i, t := range want {
+		if !types.Identical(tuple.At(i).Type(), t) {
+			return false
+		}
+	}
+	return true
+}
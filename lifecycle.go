@@ -0,0 +1,35 @@
+package kami
+
+import (
+	"sync/atomic"
+)
+
+var (
+	startHooks    []func()
+	shutdownHooks []func()
+
+	shuttingDown int32 // atomic
+)
+
+// OnStart registers a function to be called when Serve starts,
+// after listeners are opened but before any requests are handled.
+// Hooks are called in order of registration. Adding hooks is not threadsafe.
+func OnStart(hook func()) {
+	startHooks = append(startHooks, hook)
+}
+
+// OnShutdown registers a function to be called after Serve gracefully shuts down,
+// once every connection has finished. This is a good place to close databases and such.
+// Hooks are called in order of registration. Adding hooks is not threadsafe.
+func OnShutdown(hook func()) {
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// ShuttingDown returns true once a graceful shutdown has begun.
+func ShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+func setShuttingDown() {
+	atomic.StoreInt32(&shuttingDown, 1)
+}
@@ -0,0 +1,246 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter limits the number of requests handled at the same time, shedding the excess.
+// Register it for a path with Limit. A Limiter may be shared between paths to give them a common limit.
+// Requests over the limit wait in a queue if QueueSize and QueueTimeout are set,
+// otherwise they are rejected with 503 Service Unavailable and a Retry-After header.
+// Rejected requests skip middleware and the handler, but afterware and LogHandler still run.
+// Use kami.Shed(ctx) to tell them apart.
+//
+// If TargetLatency is set, the limit adapts to observed latency: it shrinks when requests
+// are slower than the target and slowly grows back to Max when they are faster.
+type Limiter struct {
+	// Max is the maximum number of requests in flight.
+	Max int
+	// QueueSize is the maximum number of requests waiting for their turn.
+	QueueSize int
+	// QueueTimeout is how long a request may wait in the queue before being rejected.
+	QueueTimeout time.Duration
+	// RetryAfter is sent as the Retry-After header of rejected requests.
+	// If zero, one second is used.
+	RetryAfter time.Duration
+	// ShedHandler, if set, is called to respond to rejected requests.
+	// By default, a plain 503 Service Unavailable response is sent.
+	ShedHandler HandlerType
+
+	// TargetLatency enables adaptive limiting when non-zero.
+	TargetLatency time.Duration
+	// MinLimit is the lowest the adaptive limit will go. If zero, 1 is used.
+	MinLimit int
+
+	mu       sync.Mutex
+	inflight int
+	limit    float64 // current adaptive limit; 0 until first use
+	queue    []chan struct{}
+	shed     uint64 // atomic
+}
+
+// limits are the Limiters registered with wares.
+type limits struct {
+	limiters map[string][]*Limiter
+}
+
+// NewLimiter creates a Limiter that allows up to max requests at a time.
+func NewLimiter(max int) *Limiter {
+	return &Limiter{Max: max}
+}
+
+// Limit registers a Limiter for the given path and every path under it, like Use.
+// When several limiters apply to a request, it needs room in all of them.
+// Wildcard paths are not supported.
+func Limit(path string, limiter *Limiter) {
+	defaultMW.Limit(path, limiter)
+}
+
+// Limit registers a Limiter for the given path and every path under it.
+// See the global Limit function's documents for more information.
+func (m *wares) Limit(path string, limiter *Limiter) {
+	if m.limiters == nil {
+		m.limiters = make(map[string][]*Limiter)
+	}
+	m.limiters[path] = append(m.limiters[path], limiter)
+}
+
+// Shed returns true if this request was rejected by a Limiter.
+// It is useful in afterware and LogHandler.
+func Shed(ctx context.Context) bool {
+	shed, _ := ctx.Value(shedKey{}).(bool)
+	return shed
+}
+
+type shedKey struct{}
+
+// InFlight returns the number of requests currently being handled.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// Shed returns the number of requests rejected so far.
+func (l *Limiter) Shed() uint64 {
+	return atomic.LoadUint64(&l.shed)
+}
+
+// Limit returns the current limit, which may be lower than Max if adaptive limiting is enabled.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.currentLimit())
+}
+
+// acquire waits for room to handle a request, returning false if the request should be shed.
+func (l *Limiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	if float64(l.inflight) < l.currentLimit() {
+		l.inflight++
+		l.mu.Unlock()
+		return true
+	}
+	if len(l.queue) >= l.QueueSize || l.QueueTimeout <= 0 {
+		l.mu.Unlock()
+		atomic.AddUint64(&l.shed, 1)
+		return false
+	}
+	ready := make(chan struct{})
+	l.queue = append(l.queue, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, ch := range l.queue {
+		if ch == ready {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			atomic.AddUint64(&l.shed, 1)
+			return false
+		}
+	}
+	// we got our turn just as we gave up
+	return true
+}
+
+// release gives back room taken by acquire.
+// A negative latency means the request didn't run and shouldn't affect the adaptive limit.
+func (l *Limiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.TargetLatency > 0 && latency >= 0 {
+		limit := l.currentLimit()
+		if latency > l.TargetLatency {
+			limit *= 0.9
+		} else {
+			limit += 1 / limit
+		}
+		l.limit = limit
+		l.currentLimit() // clamp
+	}
+
+	if len(l.queue) > 0 && float64(l.inflight) <= l.currentLimit() {
+		// hand our spot to the next in line
+		next := l.queue[0]
+		l.queue = l.queue[1:]
+		close(next)
+		return
+	}
+	l.inflight--
+}
+
+// currentLimit returns the limit, keeping it between MinLimit and Max.
+// l.mu must be held.
+func (l *Limiter) currentLimit() float64 {
+	max := float64(l.Max)
+	if l.TargetLatency <= 0 {
+		return max
+	}
+	min := float64(l.MinLimit)
+	if min < 1 {
+		min = 1
+	}
+	if l.limit == 0 || l.limit > max {
+		l.limit = max
+	}
+	if l.limit < min {
+		l.limit = min
+	}
+	return l.limit
+}
+
+// reject responds to a shed request.
+func (l *Limiter) reject(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	retry := l.RetryAfter
+	if retry <= 0 {
+		retry = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+	if l.ShedHandler != nil {
+		wrap(l.ShedHandler).ServeHTTPContext(ctx, w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// limit acquires room from every limiter registered for this request's path.
+// If a limiter is full, the request is rejected and ok is false.
+// release must be called when the request is done, even if it was rejected.
+func (m *wares) limit(ctx context.Context, w http.ResponseWriter, r *http.Request) (release func(), newCtx context.Context, ok bool) {
+	var acquired []*Limiter
+	var start time.Time
+	release = func() {
+		latency := time.Since(start)
+		if !ok {
+			latency = -1
+		}
+		for _, l := range acquired {
+			l.release(latency)
+		}
+	}
+
+	path := r.URL.Path
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' || i == len(path)-1 {
+			for _, l := range m.limiters[path[:i+1]] {
+				if containsLimiter(acquired, l) {
+					continue
+				}
+				if !l.acquire(ctx) {
+					ctx = context.WithValue(ctx, shedKey{}, true)
+					l.reject(ctx, w, r.WithContext(ctx))
+					return release, ctx, false
+				}
+				acquired = append(acquired, l)
+			}
+		}
+	}
+	start = time.Now()
+	return release, ctx, true
+}
+
+func containsLimiter(limiters []*Limiter, l *Limiter) bool {
+	for _, x := range limiters {
+		if x == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,6 @@
+// +build !go1.7
+
+package kami
+
+// limits are not supported before Go 1.7.
+type limits struct{}
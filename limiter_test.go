@@ -0,0 +1,182 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/web/mutil"
+
+	"github.com/guregu/kami"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := kami.NewLimiter(1)
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	var mu sync.Mutex
+	var shedLogged int
+	mux := kami.New()
+	mux.LogHandler = func(ctx context.Context, w mutil.WriterProxy, r *http.Request) {
+		if kami.Shed(ctx) {
+			if w.Status() != http.StatusServiceUnavailable {
+				t.Error("shed request has wrong status:", w.Status())
+			}
+			mu.Lock()
+			shedLogged++
+			mu.Unlock()
+		}
+	}
+	mux.Limit("/api/", limiter)
+	mux.Use("/api/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if kami.Shed(ctx) {
+			t.Error("middleware shouldn't run for shed requests")
+		}
+		return ctx
+	})
+	mux.Get("/api/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Get("/api/fast", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Get("/unlimited", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	done := make(chan struct{})
+	go func() {
+		get("/api/slow")
+		close(done)
+	}()
+	<-started
+
+	resp := get("/api/fast")
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Error("expected 503, got", resp.Code)
+	}
+	if resp.Header().Get("Retry-After") != "1" {
+		t.Error("bad Retry-After:", resp.Header().Get("Retry-After"))
+	}
+	if resp := get("/unlimited"); resp.Code != http.StatusOK {
+		t.Error("unlimited path should be OK, got", resp.Code)
+	}
+
+	close(unblock)
+	<-done
+	if resp := get("/api/fast"); resp.Code != http.StatusOK {
+		t.Error("expected OK after slow request finished, got", resp.Code)
+	}
+	if limiter.Shed() != 1 || shedLogged != 1 {
+		t.Error("expected 1 shed request, got", limiter.Shed(), shedLogged)
+	}
+	if limiter.InFlight() != 0 {
+		t.Error("expected no requests in flight, got", limiter.InFlight())
+	}
+}
+
+func TestLimiterUnicodePath(t *testing.T) {
+	limiter := kami.NewLimiter(1)
+	mux := kami.New()
+	mux.Limit("/café", limiter)
+	mux.Get("/café", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if n := limiter.InFlight(); n != 1 {
+			t.Error("want 1 request in flight, got", n)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/caf%C3%A9", nil))
+	if resp.Code != http.StatusOK {
+		t.Error("unexpected status:", resp.Code)
+	}
+}
+
+func TestLimiterQueue(t *testing.T) {
+	limiter := &kami.Limiter{
+		Max:          1,
+		QueueSize:    1,
+		QueueTimeout: time.Second,
+	}
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	mux := kami.New()
+	mux.Limit("/", limiter)
+	mux.Get("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+	})
+	mux.Get("/queued", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	go func() {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		mux.ServeHTTP(resp, req)
+	}()
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(unblock)
+	}()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queued", nil)
+	mux.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Error("queued request should be OK, got", resp.Code)
+	}
+	if limiter.Shed() != 0 {
+		t.Error("nothing should be shed, got", limiter.Shed())
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := &kami.Limiter{
+		Max:           10,
+		MinLimit:      2,
+		TargetLatency: time.Millisecond,
+	}
+	mux := kami.New()
+	mux.Limit("/", limiter)
+	mux.Get("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	})
+	mux.Get("/fast", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < 50; i++ {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		mux.ServeHTTP(resp, req)
+	}
+	if limiter.Limit() != 2 {
+		t.Error("limit should shrink to the minimum, got", limiter.Limit())
+	}
+
+	for i := 0; i < 200; i++ {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/fast", nil)
+		mux.ServeHTTP(resp, req)
+	}
+	if limiter.Limit() <= 2 {
+		t.Error("limit should grow again, got", limiter.Limit())
+	}
+}
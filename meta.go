@@ -0,0 +1,78 @@
+package kami
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Meta is metadata about a route, attached with WithMeta.
+// kami doesn't use it, but middleware can read it with RouteInfo,
+// such as to check scopes declared next to each handler:
+// 	kami.Delete("/users/:id", kami.WithMeta(deleteUser, kami.Meta{Scopes: []string{"users:write"}}))
+type Meta struct {
+	// Summary is a short description of what the route does.
+	Summary string
+	// Description is a longer explanation.
+	Description string
+	// Tags group related routes.
+	Tags []string
+	// Scopes are the authorization scopes needed to use the route.
+	Scopes []string
+	// RateLimit names the class of rate limit that applies to the route.
+	RateLimit string
+	// Deprecated marks routes that shouldn't be used anymore.
+	Deprecated bool
+	// Extra holds any other metadata.
+	Extra map[string]interface{}
+}
+
+// Route describes a registered route and its metadata.
+// It is shared between requests, so it must not be modified.
+type Route struct {
+	Method string
+	Path   string
+	Meta
+
+	request, response reflect.Type // for handlers made with JSON
+}
+
+// Routes returns the routes registered with the global router, sorted by path and method.
+// Routes with several handlers for different Matchers are listed once,
+// with the metadata of the handler registered without matchers, or else the last one.
+func Routes() []Route {
+	return table.list()
+}
+
+// Routes returns the routes registered with this mux, sorted by path and method.
+// See the global Routes function's documents for more information.
+func (m *Mux) Routes() []Route {
+	return m.table.list()
+}
+
+// list returns the registered routes.
+func (t *routeTable) list() []Route {
+	t.mu.Lock()
+	routes := make([]Route, 0, len(t.routes))
+	for _, rt := range t.routes {
+		if n := len(rt.handlers); n > 0 && rt.handlers[n-1].info != nil {
+			routes = append(routes, *rt.handlers[n-1].info)
+		} else {
+			routes = append(routes, Route{Method: rt.method, Path: rt.path})
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Sort(byPath(routes))
+	return routes
+}
+
+type byPath []Route
+
+func (r byPath) Len() int      { return len(r) }
+func (r byPath) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byPath) Less(i, j int) bool {
+	if r[i].Path != r[j].Path {
+		return r[i].Path < r[j].Path
+	}
+	return r[i].Method < r[j].Method
+}
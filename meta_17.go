@@ -0,0 +1,73 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithMeta attaches metadata to a handler, to be registered with Handle, Get, etc.
+// It can be combined with WithTimeout.
+func WithMeta(handler HandlerType, meta Meta) ContextHandler {
+	ro := withRouteOptions(handler)
+	ro.meta = &meta
+	return ro
+}
+
+// RouteInfo returns the route handling this request, with its metadata.
+// It returns nil for requests that don't match a route, such as those given to NotFound.
+func RouteInfo(ctx context.Context) *Route {
+	route, _ := ctx.Value(routeKey{}).(*Route)
+	return route
+}
+
+type routeKey struct{}
+
+// routeOptions is a handler with settings for its route, given by WithTimeout and WithMeta.
+// bless unwraps it.
+type routeOptions struct {
+	handler ContextHandler
+	timeout time.Duration // zero if not set, negative if disabled
+	meta    *Meta
+	route   *Route // set when registered
+}
+
+func (ro *routeOptions) ServeHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ro.handler.ServeHTTPContext(ctx, w, r)
+}
+
+// withRouteOptions returns a copy of handler's route options, so they can be added to.
+func withRouteOptions(handler HandlerType) *routeOptions {
+	if ro, ok := handler.(*routeOptions); ok {
+		cp := *ro
+		return &cp
+	}
+	return &routeOptions{handler: wrap(handler)}
+}
+
+// routed attaches the route a handler is being registered for.
+func routed(h ContextHandler, method, path string) (ContextHandler, *Route) {
+	ro := withRouteOptions(h)
+	ro.route = &Route{Method: method, Path: path}
+	if ro.meta != nil {
+		ro.route.Meta = *ro.meta
+	}
+	if th, ok := ro.handler.(typedHandler); ok {
+		ro.route.request, ro.route.response = th.types()
+	}
+	return ro, ro.route
+}
+
+// unwrapRoute sets up k to run a handler with its route options.
+func (k *kami) unwrapRoute(h ContextHandler) {
+	ro, ok := h.(*routeOptions)
+	if !ok {
+		k.handler = h
+		return
+	}
+	k.handler = ro.handler
+	k.timeout = ro.timeout
+	k.route = ro.route
+}
@@ -0,0 +1,9 @@
+// +build !go1.7
+
+package kami
+
+// routed returns the route a handler is being registered for.
+// Handlers can't see it before Go 1.7, so it isn't attached.
+func routed(h ContextHandler, method, path string) (ContextHandler, *Route) {
+	return h, &Route{Method: method, Path: path}
+}
@@ -0,0 +1,83 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+func TestRouteInfo(t *testing.T) {
+	mux := kami.New()
+	// authorization middleware enforcing scopes declared on each route
+	mux.Use("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		route := kami.RouteInfo(ctx)
+		if route == nil {
+			return ctx
+		}
+		granted := strings.Split(r.Header.Get("X-Scopes"), ",")
+		for _, scope := range route.Scopes {
+			if !containsString(granted, scope) {
+				w.WriteHeader(http.StatusForbidden)
+				return nil
+			}
+		}
+		w.Header().Set("X-Route", route.Method+" "+route.Path)
+		return ctx
+	})
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	deleteUser := kami.WithMeta(ok, kami.Meta{
+		Summary:    "Delete a user",
+		Tags:       []string{"users"},
+		Scopes:     []string{"users:write"},
+		RateLimit:  "strict",
+		Deprecated: true,
+	})
+	mux.Delete("/users/:id", deleteUser)
+	mux.Get("/users/:id", ok)
+	mux.Post("/slow", kami.WithTimeout(kami.WithMeta(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("lost timeout")
+		}
+		if kami.RouteInfo(ctx).Summary != "slow" {
+			t.Error("lost metadata")
+		}
+		w.WriteHeader(http.StatusOK)
+	}, kami.Meta{Summary: "slow"}), time.Second))
+
+	expect := func(method, path, scopes string, code int, route string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Scopes", scopes)
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Errorf("%s %s: want %d, got %d", method, path, code, resp.Code)
+		}
+		if got := resp.Header().Get("X-Route"); got != route {
+			t.Errorf("%s %s: want route %q, got %q", method, path, route, got)
+		}
+	}
+	expect("DELETE", "/users/1", "", http.StatusForbidden, "")
+	expect("DELETE", "/users/1", "users:read,users:write", http.StatusOK, "DELETE /users/:id")
+	expect("GET", "/users/1", "", http.StatusOK, "GET /users/:id")
+	expect("POST", "/slow", "", http.StatusOK, "POST /slow")
+	expect("GET", "/missing", "", http.StatusNotFound, "")
+}
+
+func containsString(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
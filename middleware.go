@@ -1,7 +1,9 @@
 package kami
 
 import (
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/guregu/kami/treemux"
 )
@@ -11,6 +13,10 @@ type wares struct {
 	afterware      map[string][]Afterware
 	wildcards      *treemux.TreeMux
 	afterWildcards *treemux.TreeMux
+	timeouts       map[string]time.Duration
+	ordering       map[interface{}][]orderedMiddleware
+	origins        map[interface{}]wildcardOrigin
+	limits         // in versioned files
 }
 
 func newWares() *wares {
@@ -19,33 +25,42 @@ func newWares() *wares {
 
 // Use registers middleware to run for the given path.
 // See the global Use function's documents for information on how middleware works.
-func (m *wares) Use(path string, mw MiddlewareType) {
+func (m *wares) Use(path string, mw MiddlewareType, options ...UseOption) {
+	m.addMiddleware(path, mw, options, callerSource())
+}
+
+func (m *wares) addMiddleware(path string, mw MiddlewareType, options []UseOption, source string) {
+	opts := newUseOptions(options)
+	fn := withUseOptions(convert(mw), opts)
 	if containsWildcard(path) {
 		if m.wildcards == nil {
 			m.wildcards = treemux.New()
 		}
-		mw := convert(mw)
+		// patterns that only differ by wildcard names share a node, and its middleware
 		iface, _ := m.wildcards.Get(path)
-		if chain, ok := iface.(*[]Middleware); ok {
-			*chain = append(*chain, mw)
+		if node, ok := iface.(*[]Middleware); ok {
+			*node = m.order(node, fn, opts)
 		} else {
-			chain := []Middleware{mw}
-			m.wildcards.Set(path, &chain)
+			node := new([]Middleware)
+			*node = m.order(node, fn, opts)
+			m.wildcards.Set(path, node)
+			m.setOrigin(node, path, source)
 		}
 	} else {
 		if m.middleware == nil {
 			m.middleware = make(map[string][]Middleware)
 		}
-		fn := convert(mw)
-		chain := m.middleware[path]
-		chain = append(chain, fn)
-		m.middleware[path] = chain
+		m.middleware[path] = m.order(path, fn, opts)
 	}
 }
 
 // After registers middleware to run for the given path after normal middleware added with Use has run.
 // See the global After function's documents for information on how middleware works.
 func (m *wares) After(path string, afterware AfterwareType) {
+	m.addAfterware(path, afterware, callerSource())
+}
+
+func (m *wares) addAfterware(path string, afterware AfterwareType, source string) {
 	aw := convertAW(afterware)
 	if containsWildcard(path) {
 		if m.afterWildcards == nil {
@@ -57,6 +72,7 @@ func (m *wares) After(path string, afterware AfterwareType) {
 		} else {
 			chain := []Afterware{aw}
 			m.afterWildcards.Set(path, &chain)
+			m.setOrigin(&chain, path, source)
 		}
 	} else {
 		if m.afterware == nil {
@@ -66,6 +82,43 @@ func (m *wares) After(path string, afterware AfterwareType) {
 	}
 }
 
+// wildcardOrigin is the first path registered for a wildcard node, and where it was registered.
+type wildcardOrigin struct {
+	path, source string
+}
+
+func (m *wares) setOrigin(node interface{}, path, source string) {
+	if m.origins == nil {
+		m.origins = make(map[interface{}]wildcardOrigin)
+	}
+	m.origins[node] = wildcardOrigin{path: path, source: source}
+}
+
+// ambiguous returns an error if path would share a node in tree with a path
+// that names its wildcards differently, such as /users/:id and /users/:name.
+func (m *wares) ambiguous(tree *treemux.TreeMux, path, source string) error {
+	if tree == nil || !containsWildcard(path) {
+		return nil
+	}
+	node, params := tree.Get(path)
+	if node == nil {
+		return nil
+	}
+	for name, value := range params {
+		if value != ":"+name && value != "*"+name {
+			origin := m.origins[node]
+			return &RegistrationError{
+				Path:           path,
+				Source:         source,
+				Reason:         "wildcards are ambiguous with existing middleware",
+				Conflict:       origin.path,
+				ConflictSource: origin.source,
+			}
+		}
+	}
+	return nil
+}
+
 var defaultMW = newWares() // for the global router
 
 // Use registers middleware to run for the given path.
@@ -81,8 +134,118 @@ var defaultMW = newWares() // for the global router
 // standard loggers and panic handlers, will not work as expected.
 // Use kami.LogHandler and kami.PanicHandler instead.
 // Standard middleware that does not call the next handler to stop the request is supported.
-func Use(path string, mw MiddlewareType) {
-	defaultMW.Use(path, mw)
+//
+// Options can restrict middleware to some methods or exclude paths under the given path,
+// or order it relative to other middleware with Name, RunBefore, and RunAfter:
+// 	kami.Use("/api/", requireLogin, kami.Except("/api/login", "/api/health/*"))
+// 	kami.Use("/api/", checkCSRF, kami.Methods("POST", "PUT", "PATCH", "DELETE"))
+func Use(path string, mw MiddlewareType, options ...UseOption) {
+	defaultMW.Use(path, mw, options...)
+}
+
+// UseOption changes how middleware given to Use runs.
+type UseOption func(*useOptions)
+
+// Methods runs middleware only for requests with the given methods.
+// HEAD requests count as GET requests.
+func Methods(methods ...string) UseOption {
+	return func(o *useOptions) {
+		for _, method := range methods {
+			o.methods = append(o.methods, strings.ToUpper(method))
+		}
+	}
+}
+
+// Except skips middleware for requests to the given paths.
+// Paths ending with * skip every path that starts with the rest of it,
+// so "/api/health/*" skips "/api/health/" and everything under it.
+func Except(paths ...string) UseOption {
+	return func(o *useOptions) {
+		o.except = append(o.except, paths...)
+	}
+}
+
+// Name names middleware so that other middleware under the same path can be ordered around it
+// with RunBefore and RunAfter. Names must be unique within a path.
+func Name(name string) UseOption {
+	return func(o *useOptions) {
+		o.name = name
+	}
+}
+
+// RunBefore runs middleware before the named middleware registered under the same path,
+// no matter which was registered first.
+// Names that aren't registered (yet) are ignored.
+// 	kami.Use("/", loadSession, kami.Name("session"))
+// 	kami.Use("/", requireLogin, kami.Name("auth"), kami.RunAfter("session"))
+// 	kami.Use("/", realIP, kami.RunBefore("session", "auth"))
+func RunBefore(names ...string) UseOption {
+	return func(o *useOptions) {
+		o.before = append(o.before, names...)
+	}
+}
+
+// RunAfter runs middleware after the named middleware registered under the same path,
+// no matter which was registered first.
+// Names that aren't registered (yet) are ignored.
+func RunAfter(names ...string) UseOption {
+	return func(o *useOptions) {
+		o.after = append(o.after, names...)
+	}
+}
+
+type useOptions struct {
+	methods []string
+	except  []string
+
+	name          string
+	before, after []string
+}
+
+func newUseOptions(options []UseOption) *useOptions {
+	if len(options) == 0 {
+		return nil
+	}
+	o := new(useOptions)
+	for _, opt := range options {
+		opt(o)
+	}
+	return o
+}
+
+// filters returns true if the options restrict which requests middleware runs for.
+func (o *useOptions) filters() bool {
+	return o != nil && (len(o.methods) > 0 || len(o.except) > 0)
+}
+
+// skip returns true if middleware shouldn't run for this request.
+func (o *useOptions) skip(r *http.Request) bool {
+	if len(o.methods) > 0 {
+		method := r.Method
+		if method == "HEAD" {
+			method = "GET"
+		}
+		found := false
+		for _, m := range o.methods {
+			if m == method || m == r.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	for _, path := range o.except {
+		if prefix := strings.TrimSuffix(path, "*"); prefix != path {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		} else if r.URL.Path == path {
+			return true
+		}
+	}
+	return false
 }
 
 // After registers afterware to run after middleware and the request handler has run.
@@ -41,6 +41,19 @@ type Afterware func(context.Context, mutil.WriterProxy, *http.Request) context.C
 //  - Middleware
 type AfterwareType interface{}
 
+// withUseOptions wraps middleware to skip requests excluded by options.
+func withUseOptions(mw Middleware, opts *useOptions) Middleware {
+	if !opts.filters() {
+		return mw
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		if opts.skip(r) {
+			return ctx
+		}
+		return mw(ctx, w, r)
+	}
+}
+
 // run runs the middleware chain for a particular request.
 // run returns false if it should stop early.
 func (m *wares) run(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, bool) {
@@ -2,6 +2,7 @@ package kami_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/guregu/kami"
@@ -58,3 +59,93 @@ func TestHierarchicalStop(t *testing.T) {
 
 	expectResponseCode(t, "DELETE", "/nope/test", http.StatusForbidden)
 }
+
+func TestUseOptions(t *testing.T) {
+	kami.Reset()
+	deny := func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	kami.Use("/api/", deny, kami.Except("/api/login", "/api/health/*"))
+	kami.Use("/api/health/", deny, kami.Methods("post"))
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	kami.Get("/api/users", ok)
+	kami.Post("/api/login", ok)
+	kami.Get("/api/login/reset", ok)
+	kami.Get("/api/health/", ok)
+	kami.Get("/api/health/db", ok)
+	kami.Post("/api/health/db", ok)
+
+	expectResponseCode(t, "GET", "/api/users", http.StatusForbidden)
+	expectResponseCode(t, "POST", "/api/login", http.StatusOK)
+	expectResponseCode(t, "GET", "/api/login/reset", http.StatusForbidden)
+	expectResponseCode(t, "GET", "/api/health/", http.StatusOK)
+	expectResponseCode(t, "GET", "/api/health/db", http.StatusOK)
+	expectResponseCode(t, "POST", "/api/health/db", http.StatusForbidden)
+
+	kami.Reset()
+	kami.Use("/api/:id/", deny, kami.Methods("GET"))
+	kami.Get("/api/public/", ok)
+	kami.Head("/api/public/", ok)
+	kami.Put("/api/public/", ok)
+	expectResponseCode(t, "GET", "/api/public/", http.StatusForbidden)
+	expectResponseCode(t, "HEAD", "/api/public/", http.StatusForbidden)
+	expectResponseCode(t, "PUT", "/api/public/", http.StatusOK)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	kami.Reset()
+	var trace []string
+	mark := func(name string) kami.Middleware {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+			trace = append(trace, name)
+			return ctx
+		}
+	}
+	// registered in the "wrong" order, as if by different packages' init
+	kami.Use("/", mark("auth"), kami.Name("auth"), kami.RunAfter("session"))
+	kami.Use("/", mark("plain"))
+	kami.Use("/", mark("session"), kami.Name("session"))
+	kami.Use("/", mark("ip"), kami.Name("ip"), kami.RunBefore("session", "auth"))
+	kami.Use("/:id", mark("wild-last"), kami.RunAfter("wild"))
+	kami.Use("/:id", mark("wild"), kami.Name("wild"))
+	kami.Get("/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	})
+
+	expectResponseCode(t, "GET", "/x", http.StatusOK)
+	want := "plain ip session auth wild wild-last handler"
+	if got := strings.Join(trace, " "); got != want {
+		t.Errorf("want order %q, got %q", want, got)
+	}
+
+	// differently named wildcards share a node, and keep each other's middleware
+	kami.Use("/w/:a", mark("a"))
+	kami.Use("/w/:b", mark("b"))
+	kami.Use("/c/*rest", mark("rest"))
+	kami.Use("/c/:x", mark("x"))
+	kami.Get("/w/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	kami.Get("/c/*path", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	trace = nil
+	expectResponseCode(t, "GET", "/w/1", http.StatusOK)
+	expectResponseCode(t, "GET", "/c/1", http.StatusOK)
+	if got, want := strings.Join(trace, " "), "plain ip session auth a b plain ip session auth rest x"; got != want {
+		t.Errorf("want wildcard middleware %q, got %q", want, got)
+	}
+
+	trace = nil
+	err := kami.TryUse("/", mark("loop"), kami.Name("loop"), kami.RunBefore("ip"), kami.RunAfter("auth"))
+	if err == nil || !strings.Contains(err.Error(), `cycle: "loop" -> "ip" -> "session" -> "auth" -> "loop"`) {
+		t.Error("want cycle error, got", err)
+	}
+	if err := kami.TryUse("/", mark("dupe"), kami.Name("auth")); err == nil {
+		t.Error("want error for duplicate name")
+	}
+	trace = nil
+	expectResponseCode(t, "GET", "/x", http.StatusOK)
+	if got := strings.Join(trace, " "); got != want {
+		t.Errorf("failed registrations changed the order: want %q, got %q", want, got)
+	}
+}
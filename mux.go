@@ -10,7 +10,8 @@ import (
 	"golang.org/x/net/context"
 )
 
-// Mux is an independent kami router and middleware stack. Manipulating it is not threadsafe.
+// Mux is an independent kami router and middleware stack. Manipulating it is not threadsafe,
+// except for changing routes with Update, Remove, and Replace.
 type Mux struct {
 	// Context is the root "god object" for this mux,
 	// from which every request's context will derive.
@@ -23,69 +24,90 @@ type Mux struct {
 	// LogHandler will, if set, wrap every request and be called at the very end.
 	LogHandler func(context.Context, mutil.WriterProxy, *http.Request)
 
-	routes    *httptreemux.TreeMux
+	table     *routeTable
 	enable405 bool
 	*wares
 }
 
 // New creates a new independent kami router and middleware stack.
 // It is totally separate from the global kami.Context and middleware stack.
-func New() *Mux {
+// Optionally, RouterOptions may be given to change how paths are matched.
+func New(options ...RouterOptions) *Mux {
 	m := &Mux{
 		Context:   context.Background(),
-		routes:    newRouter(),
+		table:     newRouteTable(),
 		wares:     newWares(),
 		enable405: true,
 	}
 	m.NotFound(nil)
 	m.MethodNotAllowed(nil)
+	m.NotAcceptable(nil)
+	m.UnsupportedMediaType(nil)
+	switch len(options) {
+	case 0:
+	case 1:
+		m.table.setOptions(options[0])
+	default:
+		panic("kami: New takes at most one RouterOptions")
+	}
 	return m
 }
 
 // ServeHTTP handles an HTTP request, running middleware and forwarding the request to the appropriate handler.
 // Implements the http.Handler interface for easy composition with other frameworks.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	m.routes.ServeHTTP(w, r)
+	m.table.ServeHTTP(w, r)
 }
 
 // Handle registers an arbitrary method handler under the given path.
-func (m *Mux) Handle(method, path string, handler HandlerType) {
-	m.routes.Handle(method, path, m.bless(wrap(handler)))
+// Optional matchers add conditions for this handler to run, see Matcher.
+func (m *Mux) Handle(method, path string, handler HandlerType, matchers ...Matcher) {
+	if err := m.table.handle(method, path, handler, m.bless, matchers); err != nil {
+		panic(err)
+	}
 }
 
 // Get registers a GET handler under the given path.
-func (m *Mux) Get(path string, handler HandlerType) {
-	m.Handle("GET", path, handler)
+func (m *Mux) Get(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("GET", path, handler, matchers...)
 }
 
 // Post registers a POST handler under the given path.
-func (m *Mux) Post(path string, handler HandlerType) {
-	m.Handle("POST", path, handler)
+func (m *Mux) Post(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("POST", path, handler, matchers...)
 }
 
 // Put registers a PUT handler under the given path.
-func (m *Mux) Put(path string, handler HandlerType) {
-	m.Handle("PUT", path, handler)
+func (m *Mux) Put(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("PUT", path, handler, matchers...)
 }
 
 // Patch registers a PATCH handler under the given path.
-func (m *Mux) Patch(path string, handler HandlerType) {
-	m.Handle("PATCH", path, handler)
+func (m *Mux) Patch(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("PATCH", path, handler, matchers...)
 }
 
 // Head registers a HEAD handler under the given path.
-func (m *Mux) Head(path string, handler HandlerType) {
-	m.Handle("HEAD", path, handler)
+func (m *Mux) Head(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("HEAD", path, handler, matchers...)
 }
 
 // Options registers a OPTIONS handler under the given path.
-func (m *Mux) Options(path string, handler HandlerType) {
-	m.Handle("OPTIONS", path, handler)
+func (m *Mux) Options(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("OPTIONS", path, handler, matchers...)
 }
 
 // Delete registers a DELETE handler under the given path.
-func (m *Mux) Delete(path string, handler HandlerType) {
-	m.Handle("DELETE", path, handler)
+func (m *Mux) Delete(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("DELETE", path, handler, matchers...)
+}
+
+// Any registers a handler for every method under the given path, including non-standard
+// methods such as WebDAV's PROPFIND. Handlers registered for specific methods take precedence.
+// HEAD requests go to the path's GET handler if it has one, before Any.
+// It is the same as Handle(AnyMethod, path, handler).
+func (m *Mux) Any(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle(AnyMethod, path, handler, matchers...)
 }
 
 // NotFound registers a special handler for unregistered (404) paths.
@@ -99,14 +121,16 @@ func (m *Mux) NotFound(handler HandlerType) {
 		})
 	}
 
-	h := m.bless(wrap(handler))
-	m.routes.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+	m.table.notFound = wrap(handler)
+	h := m.bless(m.table.notFound)
+	m.table.tree.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
 		h(w, r, nil)
 	}
 }
 
 // MethodNotAllowed registers a special handler for automatically responding
 // to invalid method requests (405).
+// The Allow header is set before the handler runs.
 func (m *Mux) MethodNotAllowed(handler HandlerType) {
 	if handler == nil {
 		handler = HandlerFunc(func(_ context.Context, w http.ResponseWriter, r *http.Request) {
@@ -117,14 +141,27 @@ func (m *Mux) MethodNotAllowed(handler HandlerType) {
 		})
 	}
 
-	h := m.bless(wrap(handler))
-	m.routes.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
-		if !m.enable405 {
-			m.routes.NotFoundHandler(w, r)
-			return
-		}
-		h(w, r, nil)
+	m.table.tree.MethodNotAllowedHandler = m.table.methodNotAllowed(m.bless(wrap(handler)), &m.enable405)
+}
+
+// NotAcceptable registers a special handler for requests that match a route,
+// except for its Accepts matcher (406).
+// If handler is nil, a plain 406 Not Acceptable response is sent.
+func (m *Mux) NotAcceptable(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusNotAcceptable)
 	}
+	m.table.notAcceptable = m.bless(wrap(handler))
+}
+
+// UnsupportedMediaType registers a special handler for requests that match a route,
+// except for its ContentType matcher (415).
+// If handler is nil, a plain 415 Unsupported Media Type response is sent.
+func (m *Mux) UnsupportedMediaType(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusUnsupportedMediaType)
+	}
+	m.table.unsupportedMediaType = m.bless(wrap(handler))
 }
 
 // EnableMethodNotAllowed enables or disables automatic Method Not Allowed handling.
@@ -133,6 +170,19 @@ func (m *Mux) EnableMethodNotAllowed(enabled bool) {
 	m.enable405 = enabled
 }
 
+// EnableAutoOptions enables or disables automatic responses to OPTIONS requests.
+// When enabled, OPTIONS requests for paths without their own OPTIONS handler get a
+// 204 No Content response with the Allow header set. Middleware still runs, so it can add
+// other headers such as CORS headers.
+// Note that this is disabled by default.
+func (m *Mux) EnableAutoOptions(enabled bool) {
+	if !enabled {
+		m.table.tree.OptionsHandler = nil
+		return
+	}
+	m.table.tree.OptionsHandler = m.table.autoOptions(m.bless(wrap(noContent)))
+}
+
 // bless creates a new kamified handler.
 func (m *Mux) bless(h ContextHandler) httptreemux.HandlerFunc {
 	k := kami{
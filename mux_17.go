@@ -10,7 +10,8 @@ import (
 	"github.com/zenazn/goji/web/mutil"
 )
 
-// Mux is an independent kami router and middleware stack. Manipulating it is not threadsafe.
+// Mux is an independent kami router and middleware stack. Manipulating it is not threadsafe,
+// except for changing routes with Update, Remove, and Replace.
 type Mux struct {
 	// Context is the root "god object" for this mux,
 	// from which every request's context will derive.
@@ -22,75 +23,145 @@ type Mux struct {
 	PanicHandler HandlerType
 	// LogHandler will, if set, wrap every request and be called at the very end.
 	LogHandler func(context.Context, mutil.WriterProxy, *http.Request)
+	// TimeoutHandler will, if set, be called to respond to requests that time out.
+	// By default, a plain 503 Service Unavailable response is sent. See Timeout.
+	TimeoutHandler HandlerType
 
-	routes    *httptreemux.TreeMux
+	table     *routeTable
 	enable405 bool
+	hosts     *hostRouter
+	parent    *Mux // for host muxes
 	*wares
+
+	// settings that host muxes inherit
+	notFound, methodNotAllowed HandlerType
+	autoOptions                bool
+	// for host muxes, the inheritable settings they've set themselves
+	own ownSettings
 }
 
 // New creates a new independent kami router and middleware stack.
 // It is totally separate from the global kami.Context and middleware stack.
-func New() *Mux {
+// Optionally, RouterOptions may be given to change how paths are matched.
+func New(options ...RouterOptions) *Mux {
+	m := newMux(nil)
+	m.Context = context.Background()
+	switch len(options) {
+	case 0:
+	case 1:
+		m.table.setOptions(options[0])
+	default:
+		panic("kami: New takes at most one RouterOptions")
+	}
+	return m
+}
+
+// newMux makes a mux with the default settings, or its parent's for a host mux.
+func newMux(parent *Mux) *Mux {
 	m := &Mux{
-		Context:   context.Background(),
-		routes:    newRouter(),
+		table:     newRouteTable(),
 		wares:     newWares(),
 		enable405: true,
+		parent:    parent,
+	}
+	if parent != nil {
+		m.enable405 = parent.enable405
+		m.table.setOptions(parent.table.options)
+		m.setAutoOptions(parent.autoOptions)
 	}
 	m.NotFound(nil)
 	m.MethodNotAllowed(nil)
+	m.NotAcceptable(nil)
+	m.UnsupportedMediaType(nil)
 	return m
 }
 
 // ServeHTTP handles an HTTP request, running middleware and forwarding the request to the appropriate handler.
 // Implements the http.Handler interface for easy composition with other frameworks.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	m.routes.ServeHTTP(w, r)
+	if m.hosts != nil {
+		if host, params := m.hosts.match(r.Host); host != nil {
+			if len(params) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), hostParamsKey{}, params))
+			}
+			host.table.ServeHTTP(w, r)
+			return
+		}
+	}
+	m.table.ServeHTTP(w, r)
 }
 
 // Handle registers an arbitrary method handler under the given path.
-func (m *Mux) Handle(method, path string, handler HandlerType) {
-	m.routes.Handle(method, path, m.bless(wrap(handler)))
+// Optional matchers add conditions for this handler to run, see Matcher.
+func (m *Mux) Handle(method, path string, handler HandlerType, matchers ...Matcher) {
+	if err := m.table.handle(method, path, handler, m.bless, matchers); err != nil {
+		panic(err)
+	}
 }
 
 // Get registers a GET handler under the given path.
-func (m *Mux) Get(path string, handler HandlerType) {
-	m.Handle("GET", path, handler)
+func (m *Mux) Get(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("GET", path, handler, matchers...)
 }
 
 // Post registers a POST handler under the given path.
-func (m *Mux) Post(path string, handler HandlerType) {
-	m.Handle("POST", path, handler)
+func (m *Mux) Post(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("POST", path, handler, matchers...)
 }
 
 // Put registers a PUT handler under the given path.
-func (m *Mux) Put(path string, handler HandlerType) {
-	m.Handle("PUT", path, handler)
+func (m *Mux) Put(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("PUT", path, handler, matchers...)
 }
 
 // Patch registers a PATCH handler under the given path.
-func (m *Mux) Patch(path string, handler HandlerType) {
-	m.Handle("PATCH", path, handler)
+func (m *Mux) Patch(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("PATCH", path, handler, matchers...)
 }
 
 // Head registers a HEAD handler under the given path.
-func (m *Mux) Head(path string, handler HandlerType) {
-	m.Handle("HEAD", path, handler)
+func (m *Mux) Head(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("HEAD", path, handler, matchers...)
 }
 
 // Options registers a OPTIONS handler under the given path.
-func (m *Mux) Options(path string, handler HandlerType) {
-	m.Handle("OPTIONS", path, handler)
+func (m *Mux) Options(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("OPTIONS", path, handler, matchers...)
 }
 
 // Delete registers a DELETE handler under the given path.
-func (m *Mux) Delete(path string, handler HandlerType) {
-	m.Handle("DELETE", path, handler)
+func (m *Mux) Delete(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle("DELETE", path, handler, matchers...)
+}
+
+// Any registers a handler for every method under the given path, including non-standard
+// methods such as WebDAV's PROPFIND. Handlers registered for specific methods take precedence.
+// HEAD requests go to the path's GET handler if it has one, before Any.
+// It is the same as Handle(AnyMethod, path, handler).
+func (m *Mux) Any(path string, handler HandlerType, matchers ...Matcher) {
+	m.Handle(AnyMethod, path, handler, matchers...)
 }
 
 // NotFound registers a special handler for unregistered (404) paths.
 // If handle is nil, use the default http.NotFound behavior.
+// Host muxes use this mux's handler unless they set their own; for them, nil means this mux's.
 func (m *Mux) NotFound(handler HandlerType) {
+	if m.parent != nil {
+		m.own.notFound = handler != nil
+		if handler == nil {
+			handler = m.parent.notFound
+		}
+	}
+	m.notFound = handler
+	m.setNotFound(handler)
+	m.eachHost(func(host *Mux) {
+		if !host.own.notFound {
+			host.setNotFound(handler)
+		}
+	})
+}
+
+func (m *Mux) setNotFound(handler HandlerType) {
 	// set up the default handler if needed
 	// we need to bless this so middleware will still run for a 404 request
 	if handler == nil {
@@ -99,15 +170,34 @@ func (m *Mux) NotFound(handler HandlerType) {
 		})
 	}
 
-	h := m.bless(wrap(handler))
-	m.routes.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+	m.table.notFound = wrap(handler)
+	h := m.bless(m.table.notFound)
+	m.table.tree.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
 		h(w, r, nil)
 	}
 }
 
 // MethodNotAllowed registers a special handler for automatically responding
 // to invalid method requests (405).
+// The Allow header is set before the handler runs.
+// Host muxes use this mux's handler unless they set their own; for them, nil means this mux's.
 func (m *Mux) MethodNotAllowed(handler HandlerType) {
+	if m.parent != nil {
+		m.own.methodNotAllowed = handler != nil
+		if handler == nil {
+			handler = m.parent.methodNotAllowed
+		}
+	}
+	m.methodNotAllowed = handler
+	m.setMethodNotAllowed(handler)
+	m.eachHost(func(host *Mux) {
+		if !host.own.methodNotAllowed {
+			host.setMethodNotAllowed(handler)
+		}
+	})
+}
+
+func (m *Mux) setMethodNotAllowed(handler HandlerType) {
 	if handler == nil {
 		handler = HandlerFunc(func(_ context.Context, w http.ResponseWriter, r *http.Request) {
 			http.Error(w,
@@ -117,31 +207,92 @@ func (m *Mux) MethodNotAllowed(handler HandlerType) {
 		})
 	}
 
-	h := m.bless(wrap(handler))
-	m.routes.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
-		if !m.enable405 {
-			m.routes.NotFoundHandler(w, r)
-			return
-		}
-		h(w, r, nil)
+	m.table.tree.MethodNotAllowedHandler = m.table.methodNotAllowed(m.bless(wrap(handler)), &m.enable405)
+}
+
+// NotAcceptable registers a special handler for requests that match a route,
+// except for its Accepts matcher (406).
+// If handler is nil, a plain 406 Not Acceptable response is sent.
+func (m *Mux) NotAcceptable(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusNotAcceptable)
 	}
+	m.table.notAcceptable = m.bless(wrap(handler))
+}
+
+// UnsupportedMediaType registers a special handler for requests that match a route,
+// except for its ContentType matcher (415).
+// If handler is nil, a plain 415 Unsupported Media Type response is sent.
+func (m *Mux) UnsupportedMediaType(handler HandlerType) {
+	if handler == nil {
+		handler = errorHandler(http.StatusUnsupportedMediaType)
+	}
+	m.table.unsupportedMediaType = m.bless(wrap(handler))
 }
 
 // EnableMethodNotAllowed enables or disables automatic Method Not Allowed handling.
 // Note that this is enabled by default.
+// Host muxes follow this mux's setting unless they set their own.
 func (m *Mux) EnableMethodNotAllowed(enabled bool) {
+	if m.parent != nil {
+		m.own.enable405 = true
+	}
 	m.enable405 = enabled
+	m.eachHost(func(host *Mux) {
+		if !host.own.enable405 {
+			host.enable405 = enabled
+		}
+	})
+}
+
+// EnableAutoOptions enables or disables automatic responses to OPTIONS requests.
+// When enabled, OPTIONS requests for paths without their own OPTIONS handler get a
+// 204 No Content response with the Allow header set. Middleware still runs, so it can add
+// other headers such as CORS headers.
+// Note that this is disabled by default.
+// Host muxes follow this mux's setting unless they set their own.
+func (m *Mux) EnableAutoOptions(enabled bool) {
+	if m.parent != nil {
+		m.own.autoOptions = true
+	}
+	m.setAutoOptions(enabled)
+	m.eachHost(func(host *Mux) {
+		if !host.own.autoOptions {
+			host.setAutoOptions(enabled)
+		}
+	})
+}
+
+func (m *Mux) setAutoOptions(enabled bool) {
+	m.autoOptions = enabled
+	if !enabled {
+		m.table.tree.OptionsHandler = nil
+		return
+	}
+	m.table.tree.OptionsHandler = m.table.autoOptions(m.bless(wrap(noContent)))
 }
 
 // bless creates a new kamified handler.
+// Host muxes use their parent's settings and run their parent's middleware first.
 func (m *Mux) bless(h ContextHandler) httptreemux.HandlerFunc {
+	root := m
+	if m.parent != nil {
+		root = m.parent
+	}
 	k := kami{
-		handler:      h,
-		base:         &m.Context,
-		autocancel:   &m.Cancel,
-		middleware:   m.wares,
-		panicHandler: &m.PanicHandler,
-		logHandler:   &m.LogHandler,
+		base:           &root.Context,
+		autocancel:     &root.Cancel,
+		middleware:     m.wares,
+		panicHandler:   &root.PanicHandler,
+		logHandler:     &root.LogHandler,
+		timeoutHandler: &root.TimeoutHandler,
+	}
+	k.unwrapRoute(h)
+	if m.parent != nil {
+		k.shared = m.parent.wares
+	}
+	if _, ok := k.handler.(notFoundFallback); ok {
+		k.notFound = &m.table.notFound
 	}
 	return k.handle
 }
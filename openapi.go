@@ -0,0 +1,327 @@
+package kami
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo describes an API for OpenAPI documents.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+	// Servers are the base URLs of the API.
+	Servers []string
+	// SecurityScheme is the name of the security scheme that routes with Scopes require.
+	// Define it under "components" → "securitySchemes" in the document.
+	SecurityScheme string
+}
+
+// OpenAPI generates an OpenAPI 3.1 document describing the routes of the global router.
+// Path parameters come from the route's path, and each operation is described by its route's Meta.
+// Routes whose handlers were made with JSON document their request and response bodies,
+// with schemas generated from the Go types.
+// Routes registered with Any are left out.
+//
+// The document is a tree of maps and slices, ready to be modified or marshaled to JSON.
+func OpenAPI(info OpenAPIInfo) map[string]interface{} {
+	return openAPI(info, Routes())
+}
+
+// OpenAPIHandler returns a handler that serves the global router's OpenAPI document as JSON.
+// The document is regenerated for each request, so it's always up to date.
+// 	kami.Get("/openapi.json", kami.OpenAPIHandler(kami.OpenAPIInfo{Title: "Greeter", Version: "1.0"}))
+func OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return openAPIHandler(func() map[string]interface{} { return OpenAPI(info) })
+}
+
+// OpenAPI generates an OpenAPI 3.1 document describing this mux's routes.
+// See the global OpenAPI function's documents for more information.
+func (m *Mux) OpenAPI(info OpenAPIInfo) map[string]interface{} {
+	return openAPI(info, m.Routes())
+}
+
+// OpenAPIHandler returns a handler that serves this mux's OpenAPI document as JSON.
+func (m *Mux) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return openAPIHandler(func() map[string]interface{} { return m.OpenAPI(info) })
+}
+
+func openAPIHandler(generate func() map[string]interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(generate())
+	})
+}
+
+// typedHandler is implemented by handlers that know the types of their request and response bodies.
+type typedHandler interface {
+	types() (request, response reflect.Type)
+}
+
+func openAPI(info OpenAPIInfo, routes []Route) map[string]interface{} {
+	gen := &schemaGen{components: make(map[string]interface{}), names: make(map[reflect.Type]string)}
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		if route.Method == AnyMethod {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = gen.operation(info, route, params)
+	}
+
+	apiInfo := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		apiInfo["description"] = info.Description
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    apiInfo,
+		"paths":   paths,
+	}
+	if len(info.Servers) > 0 {
+		servers := make([]interface{}, 0, len(info.Servers))
+		for _, url := range info.Servers {
+			servers = append(servers, map[string]interface{}{"url": url})
+		}
+		doc["servers"] = servers
+	}
+	if len(gen.components) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": gen.components}
+	}
+	return doc
+}
+
+// openAPIPath converts a kami path like "/users/:id/*rest" to "/users/{id}/{rest}",
+// returning the parameters it found.
+func openAPIPath(path string) (string, []interface{}) {
+	parts := strings.Split(path, "/")
+	var params []interface{}
+	for i, part := range parts {
+		if len(part) < 2 || (part[0] != ':' && part[0] != '*') {
+			continue
+		}
+		name := part[1:]
+		param := map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		}
+		if part[0] == '*' {
+			param["description"] = "The rest of the path, which may include slashes."
+		}
+		params = append(params, param)
+		parts[i] = "{" + name + "}"
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func (gen *schemaGen) operation(info OpenAPIInfo, route Route, params []interface{}) map[string]interface{} {
+	op := make(map[string]interface{})
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if len(route.Tags) > 0 {
+		op["tags"] = route.Tags
+	}
+	if route.Deprecated {
+		op["deprecated"] = true
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if len(route.Scopes) > 0 && info.SecurityScheme != "" {
+		op["security"] = []interface{}{
+			map[string]interface{}{info.SecurityScheme: route.Scopes},
+		}
+	}
+	for k, v := range route.Extra {
+		if strings.HasPrefix(k, "x-") {
+			op[k] = v
+		}
+	}
+
+	if route.request != nil && route.Method != "GET" && route.Method != "HEAD" && !isEmptyStruct(route.request) {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  gen.content(route.request),
+		}
+	}
+	ok := map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	if route.response != nil && !isEmptyStruct(route.response) {
+		ok["content"] = gen.content(route.response)
+	}
+	op["responses"] = map[string]interface{}{"200": ok}
+	return op
+}
+
+func (gen *schemaGen) content(t reflect.Type) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": gen.schema(t)},
+	}
+}
+
+func isEmptyStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+// schemaGen generates JSON schemas for Go types, as encoding/json would encode them.
+// Named struct types become components so that they can refer to themselves.
+type schemaGen struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage(nil))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func (gen *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawJSONType, t.Implements(marshalerType), reflect.PtrTo(t).Implements(marshalerType):
+		// could be anything
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": gen.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": gen.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return gen.object(t)
+		}
+		name, ok := gen.names[t]
+		if !ok {
+			name = gen.componentName(t)
+			gen.names[t] = name
+			gen.components[name] = map[string]interface{}{} // placeholder for recursive types
+			gen.components[name] = gen.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// object generates the schema of a struct's fields.
+func (gen *schemaGen) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	gen.fields(t, props, &required)
+	obj := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (gen *schemaGen) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, opts = tag[:comma], tag[comma:]
+		}
+		ft := field.Type
+		if field.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				// embedded fields are promoted
+				gen.fields(ft, props, required)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := gen.schema(ft)
+		if strings.Contains(opts, ",string") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		props[name] = schema
+		if !strings.Contains(opts, ",omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// componentName names a type's schema, adding its package's name if another type has the same name.
+func (gen *schemaGen) componentName(t reflect.Type) string {
+	name := sanitizeComponentName(t.Name())
+	if _, taken := gen.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if slash := strings.LastIndex(pkg, "/"); slash >= 0 {
+		pkg = pkg[slash+1:]
+	}
+	qualified := sanitizeComponentName(pkg) + "." + name
+	name = qualified
+	for i := 2; ; i++ {
+		if _, taken := gen.components[name]; !taken {
+			return name
+		}
+		name = qualified + strconv.Itoa(i)
+	}
+}
+
+// sanitizeComponentName replaces the characters OpenAPI doesn't allow in component names,
+// such as the brackets in the names of generic types.
+func sanitizeComponentName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
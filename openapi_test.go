@@ -0,0 +1,193 @@
+// +build go1.18
+
+package kami_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+type apiUser struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email,omitempty"`
+	Created time.Time `json:"created"`
+	Friends []apiUser `json:"friends,omitempty"`
+	secret  string
+}
+
+type apiNewUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+type apiError int
+
+func (e apiError) Error() string   { return http.StatusText(int(e)) }
+func (e apiError) StatusCode() int { return int(e) }
+
+func TestJSON(t *testing.T) {
+	mux := kami.New()
+	mux.Post("/users", kami.JSON(func(ctx context.Context, req apiNewUser) (apiUser, error) {
+		if req.Name == "" {
+			return apiUser{}, apiError(http.StatusUnprocessableEntity)
+		}
+		return apiUser{ID: 1, Name: req.Name}, nil
+	}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", "/users", strings.NewReader(body)))
+		return resp
+	}
+	resp := post(`{"name": "kami"}`)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/json" {
+		t.Error("want 200 JSON, got", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	var user apiUser
+	if err := json.Unmarshal(resp.Body.Bytes(), &user); err != nil || user.Name != "kami" {
+		t.Errorf("bad response %q: %v", resp.Body.String(), err)
+	}
+	if resp := post(`{"name":`); resp.Code != http.StatusBadRequest || resp.Body.String() != `{"error":"Bad Request"}`+"\n" {
+		t.Errorf("want 400 JSON error for bad JSON, got %d %q", resp.Code, resp.Body.String())
+	}
+	resp = post(``)
+	if resp.Code != http.StatusUnprocessableEntity || resp.Header().Get("Content-Type") != "application/json" {
+		t.Error("want error's status code as JSON, got", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	if resp.Body.String() != `{"error":"Unprocessable Entity"}`+"\n" {
+		t.Errorf("bad error body %q", resp.Body.String())
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	mux := kami.New()
+	mux.Get("/users/:id", kami.WithMeta(kami.JSON(func(ctx context.Context, _ struct{}) (apiUser, error) {
+		return apiUser{}, nil
+	}), kami.Meta{
+		Summary: "Get a user",
+		Tags:    []string{"users"},
+		Scopes:  []string{"users:read"},
+		Extra:   map[string]interface{}{"x-internal": true, "owner": "accounts"},
+	}))
+	mux.Post("/users", kami.JSON(func(ctx context.Context, req apiNewUser) (*apiUser, error) {
+		return nil, nil
+	}))
+	mux.Delete("/users/:id", kami.WithMeta(noop, kami.Meta{Deprecated: true}))
+	mux.Get("/files/*path", noop)
+	mux.Any("/anything", noop)
+	mux.Get("/openapi.json", mux.OpenAPIHandler(kami.OpenAPIInfo{
+		Title:          "Users",
+		Version:        "1.0",
+		Servers:        []string{"https://api.example.com"},
+		SecurityScheme: "oauth",
+	}))
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/openapi.json", nil))
+	if resp.Header().Get("Content-Type") != "application/json" {
+		t.Error("wrong content type:", resp.Header().Get("Content-Type"))
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path ...string) interface{} {
+		t.Helper()
+		var v interface{} = doc
+		for _, p := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				t.Fatalf("%v: not found", path)
+			}
+			v = m[p]
+		}
+		return v
+	}
+	expect := func(want interface{}, path ...string) {
+		t.Helper()
+		if got := get(path...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: want %v, got %v", path, want, got)
+		}
+	}
+
+	expect("3.1.0", "openapi")
+	expect("Users", "info", "title")
+	expect([]interface{}{map[string]interface{}{"url": "https://api.example.com"}}, "servers")
+
+	paths := get("paths").(map[string]interface{})
+	for _, path := range []string{"/users", "/users/{id}", "/files/{path}", "/openapi.json"} {
+		if paths[path] == nil {
+			t.Error("missing path", path)
+		}
+	}
+	if len(paths) != 4 {
+		t.Error("want 4 paths, got", len(paths))
+	}
+
+	op := []string{"paths", "/users/{id}", "get"}
+	expect("Get a user", append(op, "summary")...)
+	expect([]interface{}{"users"}, append(op, "tags")...)
+	expect([]interface{}{map[string]interface{}{"oauth": []interface{}{"users:read"}}}, append(op, "security")...)
+	expect(true, append(op, "x-internal")...)
+	expect(nil, append(op, "owner")...)
+	expect(nil, append(op, "requestBody")...)
+	expect([]interface{}{map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}}, append(op, "parameters")...)
+	expect("#/components/schemas/apiUser", append(op, "responses", "200", "content", "application/json", "schema", "$ref")...)
+	expect(true, "paths", "/users/{id}", "delete", "deprecated")
+	if params := get("paths", "/files/{path}", "get", "parameters").([]interface{}); len(params) != 1 || params[0].(map[string]interface{})["name"] != "path" {
+		t.Error("want catch-all parameter, got", params)
+	}
+
+	op = []string{"paths", "/users", "post"}
+	expect("#/components/schemas/apiNewUser", append(op, "requestBody", "content", "application/json", "schema", "$ref")...)
+	expect("#/components/schemas/apiUser", append(op, "responses", "200", "content", "application/json", "schema", "$ref")...)
+
+	user := []string{"components", "schemas", "apiUser"}
+	expect([]interface{}{"id", "name", "created"}, append(user, "required")...)
+	expect(map[string]interface{}{"type": "string", "format": "date-time"}, append(user, "properties", "created")...)
+	expect("#/components/schemas/apiUser", append(user, "properties", "friends", "items", "$ref")...)
+	expect(nil, append(user, "properties", "secret")...)
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	mux := kami.New()
+	{
+		type v2 struct{ A int }
+		mux.Get("/a", kami.JSON(func(ctx context.Context, _ struct{}) (v2, error) { return v2{}, nil }))
+	}
+	{
+		type v2 struct{ B int }
+		mux.Get("/b", kami.JSON(func(ctx context.Context, _ struct{}) (v2, error) { return v2{}, nil }))
+	}
+	{
+		type v2 struct{ C int }
+		mux.Get("/c", kami.JSON(func(ctx context.Context, _ struct{}) (v2, error) { return v2{}, nil }))
+	}
+
+	doc := mux.OpenAPI(kami.OpenAPIInfo{Title: "Names", Version: "1.0"})
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"v2", "kami_test.v2", "kami_test.v22"} {
+		if schemas[name] == nil {
+			t.Errorf("missing schema %q in %v", name, schemas)
+		}
+	}
+	if len(schemas) != 3 {
+		t.Error("want 3 schemas, got", len(schemas))
+	}
+}
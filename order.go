@@ -0,0 +1,140 @@
+package kami
+
+import (
+	"fmt"
+	"strings"
+)
+
+// orderedMiddleware is middleware registered with Use, with its ordering constraints.
+type orderedMiddleware struct {
+	mw            Middleware
+	name          string
+	before, after []string
+}
+
+// order adds middleware to a path, returning the path's middleware in the order it should run:
+// the order of registration, except where RunBefore and RunAfter say otherwise.
+// The key is the path, or the wildcard tree's node for paths with wildcards.
+// It panics if the new middleware's name is taken or its constraints can't be satisfied.
+func (m *wares) order(key interface{}, mw Middleware, opts *useOptions) []Middleware {
+	om := orderedMiddleware{mw: mw}
+	if opts != nil {
+		om.name, om.before, om.after = opts.name, opts.before, opts.after
+	}
+	existing := m.ordering[key]
+	if om.name != "" {
+		for _, other := range existing {
+			if other.name == om.name {
+				panic(fmt.Errorf("middleware named %q is already registered", om.name))
+			}
+		}
+	}
+	all := make([]orderedMiddleware, len(existing), len(existing)+1)
+	copy(all, existing)
+	all = append(all, om)
+
+	chain, err := sortMiddleware(all)
+	if err != nil {
+		panic(err)
+	}
+	if m.ordering == nil {
+		m.ordering = make(map[interface{}][]orderedMiddleware)
+	}
+	m.ordering[key] = all
+	return chain
+}
+
+// sortMiddleware sorts middleware topologically by their constraints.
+// When several could run next, the one registered first wins, so the order is deterministic.
+func sortMiddleware(all []orderedMiddleware) ([]Middleware, error) {
+	index := make(map[string]int)
+	for i, om := range all {
+		if om.name != "" {
+			index[om.name] = i
+		}
+	}
+	// preds[i] must run before i
+	preds := make([][]int, len(all))
+	for i, om := range all {
+		for _, name := range om.before {
+			if j, ok := index[name]; ok && j != i {
+				preds[j] = append(preds[j], i)
+			}
+		}
+		for _, name := range om.after {
+			if j, ok := index[name]; ok && j != i {
+				preds[i] = append(preds[i], j)
+			}
+		}
+	}
+
+	done := make([]bool, len(all))
+	chain := make([]Middleware, 0, len(all))
+	for len(chain) < len(all) {
+		next := -1
+		for i := range all {
+			if !done[i] && ready(preds[i], done) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, cycleError(all, preds, done)
+		}
+		done[next] = true
+		chain = append(chain, all[next].mw)
+	}
+	return chain, nil
+}
+
+func ready(preds []int, done []bool) bool {
+	for _, p := range preds {
+		if !done[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// cycleError describes a cycle among the middleware that couldn't be sorted.
+func cycleError(all []orderedMiddleware, preds [][]int, done []bool) error {
+	// every remaining middleware waits on another remaining one, so walking back must loop
+	var walk []int
+	seen := make(map[int]int)
+	cur := -1
+	for i := range all {
+		if !done[i] {
+			cur = i
+			break
+		}
+	}
+	for {
+		if at, ok := seen[cur]; ok {
+			walk = walk[at:]
+			break
+		}
+		seen[cur] = len(walk)
+		walk = append(walk, cur)
+		for _, p := range preds[cur] {
+			if !done[p] {
+				cur = p
+				break
+			}
+		}
+	}
+
+	// walk goes backwards; print it in running order
+	names := make([]string, 0, len(walk)+1)
+	for i := len(walk) - 1; i >= 0; i-- {
+		names = append(names, middlewareName(all, walk[i]))
+	}
+	names = append(names, names[0])
+	return fmt.Errorf("middleware order has a cycle: %s", strings.Join(names, " -> "))
+}
+
+func middlewareName(all []orderedMiddleware, i int) string {
+	if all[i].name != "" {
+		return fmt.Sprintf("%q", all[i].name)
+	}
+	return fmt.Sprintf("unnamed middleware #%d", i+1)
+}
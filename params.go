@@ -1,11 +1,15 @@
 package kami
 
 import (
+	"crypto/x509"
+
 	"golang.org/x/net/context"
 )
 
 type paramsKey struct{}
 type panicKey struct{}
+type panicStackKey struct{}
+type clientCertKey struct{}
 
 // Param returns a request path parameter, or a blank string if it doesn't exist.
 // For example, with the path /v2/papers/:page
@@ -36,6 +40,22 @@ func Exception(ctx context.Context) interface{} {
 	return ctx.Value(panicKey{})
 }
 
+// PanicStack returns the stack trace of the goroutine that panicked, as formatted by runtime/debug.Stack.
+// Like Exception, only PanicHandler will receive a context you can use this with.
+func PanicStack(ctx context.Context) []byte {
+	stack, _ := ctx.Value(panicStackKey{}).([]byte)
+	return stack
+}
+
+// ClientCert returns the verified certificate chain of a TLS client, starting with the client's own certificate.
+// It returns nil if the client did not present a certificate or the certificate was not verified.
+// Verification is done by crypto/tls, so serve with a tls.Config that sets ClientCAs and
+// a ClientAuth mode such as tls.RequireAndVerifyClientCert or tls.VerifyClientCertIfGiven.
+func ClientCert(ctx context.Context) []*x509.Certificate {
+	chain, _ := ctx.Value(clientCertKey{}).([]*x509.Certificate)
+	return chain
+}
+
 func newContextWithParams(ctx context.Context, params map[string]string) context.Context {
 	return context.WithValue(ctx, paramsKey{}, params)
 }
@@ -52,6 +72,11 @@ func mergeParams(ctx context.Context, params map[string]string) context.Context
 	return ctx
 }
 
-func newContextWithException(ctx context.Context, exception interface{}) context.Context {
-	return context.WithValue(ctx, panicKey{}, exception)
+func newContextWithException(ctx context.Context, exception interface{}, stack []byte) context.Context {
+	ctx = context.WithValue(ctx, panicKey{}, exception)
+	return context.WithValue(ctx, panicStackKey{}, stack)
+}
+
+func newContextWithClientCert(ctx context.Context, chain []*x509.Certificate) context.Context {
+	return context.WithValue(ctx, clientCertKey{}, chain)
 }
@@ -0,0 +1,302 @@
+// +build go1.7
+
+package kami
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProxyHeaderTimeout is how long a ProxyListener waits for a PROXY header by default.
+const DefaultProxyHeaderTimeout = 10 * time.Second
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyListener wraps a net.Listener, accepting connections that start with
+// a HAProxy PROXY protocol header (versions 1 and 2) from trusted load balancers.
+// Connections from a trusted proxy report the real client address from RemoteAddr,
+// so it becomes http.Request.RemoteAddr. Within kami, use ProxyAddr to get it.
+// Connections from untrusted addresses, and trusted connections without a PROXY header, are left alone.
+// On Go 1.13 and later, serving it with your own http.Server requires ProxyConnContext.
+// Use it with ServeListener:
+// 	l, err := kami.NewProxyListener(bind.Default(), "10.0.0.0/8")
+// 	// ...
+// 	kami.ServeListener(l)
+type ProxyListener struct {
+	net.Listener
+	// Trusted is the list of networks allowed to send PROXY headers.
+	Trusted []*net.IPNet
+	// HeaderTimeout is the maximum time to wait for the PROXY header.
+	// If zero, DefaultProxyHeaderTimeout is used.
+	HeaderTimeout time.Duration
+}
+
+// NewProxyListener wraps l, trusting PROXY headers from the given CIDR networks or IP addresses.
+func NewProxyListener(l net.Listener, trusted ...string) (*ProxyListener, error) {
+	nets, err := parseNetworks(trusted)
+	if err != nil {
+		return nil, err
+	}
+	return &ProxyListener{
+		Listener: l,
+		Trusted:  nets,
+	}, nil
+}
+
+// Accept waits for and returns the next connection.
+// The PROXY header is read lazily, the first time the connection is used.
+func (pl *ProxyListener) Accept() (net.Conn, error) {
+	conn, err := pl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !containsAddr(pl.Trusted, conn.RemoteAddr()) {
+		return conn, nil
+	}
+	timeout := pl.HeaderTimeout
+	if timeout == 0 {
+		timeout = DefaultProxyHeaderTimeout
+	}
+	return &proxyConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// ProxyAddr returns the real client address sent by a trusted proxy using the PROXY protocol,
+// or nil if the request didn't come through a ProxyListener.
+func ProxyAddr(ctx context.Context) net.Addr {
+	addr, _ := ctx.Value(proxyAddrKey{}).(net.Addr)
+	return addr
+}
+
+type proxyAddrKey struct{}
+
+func newContextWithProxyAddr(ctx context.Context, addr net.Addr) context.Context {
+	return context.WithValue(ctx, proxyAddrKey{}, addr)
+}
+
+// proxyConn is a connection that may start with a PROXY header.
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	err    error
+	source net.Addr
+	dest   net.Addr
+
+	mu           sync.Mutex
+	readDeadline time.Time // as set by the server
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// readHeader reads the PROXY header within the header timeout,
+// or the server's own read deadline if that's sooner, then puts the server's deadline back.
+func (c *proxyConn) readHeader() {
+	c.mu.Lock()
+	deadline := time.Now().Add(c.timeout)
+	if !c.readDeadline.IsZero() && c.readDeadline.Before(deadline) {
+		deadline = c.readDeadline
+	}
+	c.Conn.SetReadDeadline(deadline)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.Conn.SetReadDeadline(c.readDeadline)
+		c.mu.Unlock()
+	}()
+
+	c.source, c.dest, c.err = readProxyHeader(c.r)
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+// readProxyHeader reads a PROXY header from r if there is one.
+// It returns nil addresses if there is no header, or if the proxy sent the connection on its own behalf.
+func readProxyHeader(r *bufio.Reader) (source, dest net.Addr, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		// let the next Read deal with it
+		return nil, nil, nil
+	}
+	switch first[0] {
+	case 'P':
+		if sig, err := r.Peek(6); err == nil && string(sig) == "PROXY " {
+			return readProxyV1(r)
+		}
+	case proxyV2Signature[0]:
+		if sig, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(sig, proxyV2Signature) {
+			return readProxyV2(r)
+		}
+	}
+	return nil, nil, nil
+}
+
+// readProxyV1 reads a human-readable PROXY header, like:
+// 	PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n
+func readProxyV1(r *bufio.Reader) (source, dest net.Addr, err error) {
+	// the longest possible v1 header is 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("kami: reading PROXY header: %v", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("kami: PROXY header too long")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("kami: invalid PROXY header: %q", line)
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil {
+		return nil, nil, fmt.Errorf("kami: invalid PROXY header: %q", line)
+	}
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+// readProxyV2 reads a binary PROXY header.
+func readProxyV2(r *bufio.Reader) (source, dest net.Addr, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, nil, fmt.Errorf("kami: reading PROXY header: %v", err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("kami: unsupported PROXY version: %d", header[12]>>4)
+	}
+	command := header[12] & 0xF
+	family := header[13]
+	size := int(binary.BigEndian.Uint16(header[14:16]))
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, nil, fmt.Errorf("kami: reading PROXY header: %v", err)
+	}
+
+	switch command {
+	case 0x0: // LOCAL: health checks and such from the proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("kami: unsupported PROXY command: %d", command)
+	}
+
+	switch family {
+	case 0x11, 0x12: // TCP or UDP over IPv4
+		if size < 12 {
+			return nil, nil, errors.New("kami: PROXY header too short")
+		}
+		src, dst := net.IP(data[0:4]), net.IP(data[4:8])
+		srcPort, dstPort := binary.BigEndian.Uint16(data[8:10]), binary.BigEndian.Uint16(data[10:12])
+		return &net.TCPAddr{IP: src, Port: int(srcPort)}, &net.TCPAddr{IP: dst, Port: int(dstPort)}, nil
+	case 0x21, 0x22: // TCP or UDP over IPv6
+		if size < 36 {
+			return nil, nil, errors.New("kami: PROXY header too short")
+		}
+		src, dst := net.IP(data[0:16]), net.IP(data[16:32])
+		srcPort, dstPort := binary.BigEndian.Uint16(data[32:34]), binary.BigEndian.Uint16(data[34:36])
+		return &net.TCPAddr{IP: src, Port: int(srcPort)}, &net.TCPAddr{IP: dst, Port: int(dstPort)}, nil
+	}
+	// unspecified or unix sockets: use the real connection addresses
+	return nil, nil, nil
+}
+
+// parseNetworks parses CIDR networks. Plain IP addresses are treated as single host networks.
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("kami: invalid IP address: %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("kami: invalid network: %v", err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsIP returns true if ip is part of any of the given networks.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAddr is like containsIP for TCP and UDP addresses.
+func containsAddr(nets []*net.IPNet, addr net.Addr) bool {
+	switch x := addr.(type) {
+	case *net.TCPAddr:
+		return containsIP(nets, x.IP)
+	case *net.UDPAddr:
+		return containsIP(nets, x.IP)
+	}
+	return false
+}
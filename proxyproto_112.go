@@ -0,0 +1,36 @@
+// +build go1.7,!go1.13
+
+package kami
+
+import (
+	"net"
+	"net/http"
+)
+
+// proxyLocalAddr is the local address of a proxied connection.
+// Before Go 1.13, net/http puts it into every request's context,
+// which is how kami finds the proxied source.
+type proxyLocalAddr struct {
+	net.Addr
+	source net.Addr
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.source == nil {
+		return c.Conn.LocalAddr()
+	}
+	local := c.dest
+	if local == nil {
+		local = c.Conn.LocalAddr()
+	}
+	return proxyLocalAddr{Addr: local, source: c.source}
+}
+
+// proxySource returns the proxied client address for a request, if it came through a ProxyListener.
+func proxySource(r *http.Request) net.Addr {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(proxyLocalAddr); ok {
+		return addr.source
+	}
+	return nil
+}
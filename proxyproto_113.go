@@ -0,0 +1,59 @@
+// +build go1.13
+
+package kami
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+)
+
+// ProxyConnContext is a ConnContext hook for http.Server that lets ProxyAddr find
+// the client address sent to a ProxyListener. kami's Serve functions use it automatically;
+// set it yourself when serving a ProxyListener with your own http.Server:
+// 	srv := &http.Server{Handler: kami.Handler(), ConnContext: kami.ProxyConnContext}
+// 	srv.Serve(proxyListener)
+func ProxyConnContext(ctx context.Context, c net.Conn) context.Context {
+	if pc := findProxyConn(c); pc != nil {
+		return context.WithValue(ctx, proxyConnKey{}, pc)
+	}
+	return ctx
+}
+
+type proxyConnKey struct{}
+
+// proxySource returns the proxied client address for a request, if it came through a ProxyListener.
+func proxySource(r *http.Request) net.Addr {
+	if pc, ok := r.Context().Value(proxyConnKey{}).(*proxyConn); ok {
+		// the header has been read by now, since the request has
+		pc.once.Do(pc.readHeader)
+		return pc.source
+	}
+	return nil
+}
+
+// findProxyConn finds the *proxyConn under c, which may be wrapped by the server,
+// such as by goji's graceful package or crypto/tls.
+func findProxyConn(c net.Conn) *proxyConn {
+	for depth := 0; depth < 8 && c != nil; depth++ {
+		switch x := c.(type) {
+		case *proxyConn:
+			return x
+		case interface{ NetConn() net.Conn }:
+			c = x.NetConn()
+			continue
+		}
+		// wrappers that embed the conn
+		v := reflect.ValueOf(c)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		field := v.Elem().FieldByName("Conn")
+		if !field.IsValid() || !field.CanInterface() {
+			return nil
+		}
+		c, _ = field.Interface().(net.Conn)
+	}
+	return nil
+}
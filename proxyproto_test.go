@@ -0,0 +1,149 @@
+// +build go1.13
+
+package kami_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+)
+
+func TestProxyListener(t *testing.T) {
+	mux := kami.New()
+	mux.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %v", r.RemoteAddr, kami.ProxyAddr(ctx))
+	})
+
+	serve := func(trusted ...string) net.Listener {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pl, err := kami.NewProxyListener(l, trusted...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &http.Server{Handler: mux, ConnContext: kami.ProxyConnContext}
+		// servers like goji's graceful wrap connections
+		go srv.Serve(wrappedListener{pl})
+		return pl
+	}
+
+	request := func(l net.Listener, header []byte) (int, string) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.Write(header)
+		conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	trusted := serve("127.0.0.0/8")
+	defer trusted.Close()
+
+	// version 1
+	code, body := request(trusted, []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 80\r\n"))
+	if code != http.StatusOK || body != "192.0.2.1:56324 192.0.2.1:56324" {
+		t.Error("v1: unexpected response:", code, body)
+	}
+
+	// version 2
+	v2 := []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c")
+	v2 = append(v2, net.ParseIP("192.0.2.2").To4()...)
+	v2 = append(v2, net.ParseIP("198.51.100.1").To4()...)
+	v2 = append(v2, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(v2[len(v2)-4:], 40000)
+	binary.BigEndian.PutUint16(v2[len(v2)-2:], 80)
+	code, body = request(trusted, v2)
+	if code != http.StatusOK || body != "192.0.2.2:40000 192.0.2.2:40000" {
+		t.Error("v2: unexpected response:", code, body)
+	}
+
+	// no header
+	code, body = request(trusted, nil)
+	if code != http.StatusOK || !strings.HasPrefix(body, "127.0.0.1:") || !strings.HasSuffix(body, " <nil>") {
+		t.Error("no header: unexpected response:", code, body)
+	}
+
+	// untrusted proxies are ignored
+	untrusted := serve("10.0.0.0/8")
+	defer untrusted.Close()
+	code, _ = request(untrusted, []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 80\r\n"))
+	if code != http.StatusBadRequest {
+		t.Error("untrusted: expected bad request, got", code)
+	}
+}
+
+func TestProxyListenerDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, err := kami.NewProxyListener(l, "127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pl.Close()
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// a slow client that sends nothing after the header
+	client.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 80\r\n"))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// like http.Server's ReadTimeout, set before the header is read
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Error("want timeout, got", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("reading the PROXY header cleared the read deadline")
+	}
+}
+
+type wrappedListener struct {
+	net.Listener
+}
+
+func (l wrappedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn}, nil
+}
+
+type wrappedConn struct {
+	net.Conn
+}
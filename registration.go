@@ -0,0 +1,221 @@
+package kami
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// RegistrationError describes a handler or middleware that couldn't be registered.
+// Handle, Use, and friends panic with it, while TryHandle, TryUse, and TryAfter return it.
+type RegistrationError struct {
+	// Method is the handler's HTTP method. It is blank for middleware and afterware.
+	Method string
+	Path   string
+	// Source is where the registration happened, as "file:line".
+	Source string
+	// Reason explains what went wrong.
+	Reason string
+
+	// Conflict is the existing route this one conflicts with, such as "GET /users/:id",
+	// or the path of existing middleware, such as "/users/:id", if any.
+	Conflict string
+	// ConflictSource is where Conflict was registered, as "file:line".
+	ConflictSource string
+}
+
+func (e *RegistrationError) Error() string {
+	what := e.Path
+	if e.Method != "" {
+		what = e.Method + " " + e.Path
+	}
+	msg := "kami: can't register " + what
+	if e.Source != "" {
+		msg += " (" + e.Source + ")"
+	}
+	msg += ": " + e.Reason
+	if e.Conflict != "" {
+		msg += "; conflicts with " + e.Conflict
+		if e.ConflictSource != "" {
+			msg += " (" + e.ConflictSource + ")"
+		}
+	}
+	return msg
+}
+
+// TryHandle is like Handle, but returns a *RegistrationError instead of panicking
+// if the handler can't be registered.
+func TryHandle(method, path string, handler HandlerType, matchers ...Matcher) error {
+	return table.handle(method, path, handler, bless, matchers)
+}
+
+// TryUse is like Use, but returns a *RegistrationError instead of panicking
+// if the middleware can't be registered.
+func TryUse(path string, mw MiddlewareType, options ...UseOption) error {
+	return defaultMW.TryUse(path, mw, options...)
+}
+
+// TryAfter is like After, but returns a *RegistrationError instead of panicking
+// if the afterware can't be registered.
+func TryAfter(path string, aw AfterwareType) error {
+	return defaultMW.TryAfter(path, aw)
+}
+
+// TryHandle is like Handle, but returns a *RegistrationError instead of panicking
+// if the handler can't be registered.
+func (m *Mux) TryHandle(method, path string, handler HandlerType, matchers ...Matcher) error {
+	return m.table.handle(method, path, handler, m.bless, matchers)
+}
+
+// TryUse is like Use, but returns a *RegistrationError instead of panicking
+// if the middleware can't be registered.
+func (m *wares) TryUse(path string, mw MiddlewareType, options ...UseOption) error {
+	source := callerSource()
+	if err := m.ambiguous(m.wildcards, path, source); err != nil {
+		return err
+	}
+	if err := recoverError(func() { m.addMiddleware(path, mw, options, source) }); err != nil {
+		return &RegistrationError{Path: path, Source: source, Reason: err.Error()}
+	}
+	return nil
+}
+
+// TryAfter is like After, but returns a *RegistrationError instead of panicking
+// if the afterware can't be registered.
+func (m *wares) TryAfter(path string, aw AfterwareType) error {
+	source := callerSource()
+	if err := m.ambiguous(m.afterWildcards, path, source); err != nil {
+		return err
+	}
+	if err := recoverError(func() { m.addAfterware(path, aw, source) }); err != nil {
+		return &RegistrationError{Path: path, Source: source, Reason: err.Error()}
+	}
+	return nil
+}
+
+// recoverError calls f, returning its panic as an error.
+// Registration panics when something can't be registered; this lets the Try functions return it instead.
+func recoverError(f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errors.New(panicReason(v))
+		}
+	}()
+	f()
+	return nil
+}
+
+type blessFunc func(ContextHandler) httptreemux.HandlerFunc
+
+// newRouteHandler converts and blesses a handler, remembering where it was registered.
+func newRouteHandler(method, path string, handler HandlerType, bless blessFunc, matchers []Matcher) (routeHandler, error) {
+	source := callerSource()
+	var h ContextHandler
+	var info *Route
+	if err := recoverError(func() { h, info = routed(wrap(handler), method, path) }); err != nil {
+		return routeHandler{}, &RegistrationError{Method: method, Path: path, Source: source, Reason: err.Error()}
+	}
+	return routeHandler{
+		matchers: sortMatchers(matchers),
+		handler:  bless(h),
+		source:   source,
+		info:     info,
+	}, nil
+}
+
+func panicReason(v interface{}) string {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+// findConflict finds the route that rt can't be added to a tree alongside.
+// httptreemux refuses paths that only differ by wildcard names or a trailing slash.
+func findConflict(routes map[string]*route, rt *route) *route {
+	shape := routeShape(rt.path)
+	var conflict *route
+	for _, other := range routes {
+		if other == rt || routeShape(other.path) != shape {
+			continue
+		}
+		if other.method == rt.method {
+			return other
+		}
+		conflict = other
+	}
+	return conflict
+}
+
+// routeShape blanks out the wildcard names of a path, and its trailing slash.
+func routeShape(path string) string {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = ":"
+		} else if strings.HasPrefix(part, "*") {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+var kamiFuncPrefix = reflect.TypeOf(RegistrationError{}).PkgPath() + "."
+
+// registrationFuncs are the functions, without kami's package path, that sit between
+// a registration's caller and callerSource. Closures inside them count as them too.
+var registrationFuncs = func() map[string]bool {
+	funcs := map[string]bool{
+		"newRouteHandler":           true,
+		"(*routeTable).handle":      true,
+		"(*routeTable).update":      true,
+		"(*routeTable).updateAfter": true,
+		"(*RouteUpdate).Handle":     true,
+		"(*RouteUpdate).Replace":    true,
+		"(*wares).Use":              true,
+		"(*wares).TryUse":           true,
+		"(*wares).After":            true,
+		"(*wares).TryAfter":         true,
+		"Use":                       true,
+		"TryUse":                    true,
+		"After":                     true,
+		"TryAfter":                  true,
+	}
+	for _, name := range []string{
+		"Handle", "TryHandle", "Get", "Post", "Put", "Patch", "Head", "Options", "Delete", "Any",
+		"Update", "Replace",
+	} {
+		funcs[name] = true
+		funcs["(*Mux)."+name] = true
+	}
+	return funcs
+}()
+
+// callerSource returns the "file:line" that called a registration function.
+func callerSource() string {
+	for i := 1; ; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			return ""
+		}
+		if fn := runtime.FuncForPC(pc); fn != nil && isRegistrationFunc(fn.Name()) {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+}
+
+func isRegistrationFunc(name string) bool {
+	if !strings.HasPrefix(name, kamiFuncPrefix) {
+		return false
+	}
+	name = name[len(kamiFuncPrefix):]
+	if i := strings.Index(name, ".func"); i >= 0 {
+		name = name[:i]
+	}
+	return registrationFuncs[name]
+}
@@ -0,0 +1,36 @@
+package kami
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// These run inside package kami, where callerSource must still stop at the caller.
+func TestCallerSource(t *testing.T) {
+	mux := New()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	here := func(what string, err error) {
+		rerr, ok := err.(*RegistrationError)
+		if !ok {
+			t.Errorf("%s: want *RegistrationError, got %T %v", what, err, err)
+			return
+		}
+		if !strings.Contains(rerr.Source, "registration_internal_test.go:") {
+			t.Errorf("%s: source should point here, got %q", what, rerr.Source)
+		}
+	}
+
+	mux.Get("/a", noop)
+	err := mux.TryHandle("GET", "/a", noop)
+	here("TryHandle", err)
+	if rerr, ok := err.(*RegistrationError); ok && !strings.Contains(rerr.ConflictSource, "registration_internal_test.go:") {
+		t.Errorf("conflict source should point here, got %q", rerr.ConflictSource)
+	}
+	here("Replace", mux.Replace("GET", "/b", 42))
+	here("Update", mux.Update(func(u *RouteUpdate) {
+		u.Handle("GET", "/c", 42)
+	}))
+	here("TryUse", mux.TryUse("/", 42))
+	here("TryAfter", mux.TryAfter("/", 42))
+}
@@ -0,0 +1,121 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zenazn/goji/web/mutil"
+
+	"github.com/guregu/kami"
+)
+
+func TestTryHandle(t *testing.T) {
+	mux := kami.New()
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+
+	if err := mux.TryHandle("GET", "/users/:id", noop); err != nil {
+		t.Fatal(err)
+	}
+
+	err := mux.TryHandle("GET", "/users/:id", noop)
+	rerr, ok := err.(*kami.RegistrationError)
+	if !ok {
+		t.Fatalf("duplicate route: want *RegistrationError, got %T %v", err, err)
+	}
+	if rerr.Conflict != "GET /users/:id" {
+		t.Error("bad conflict:", rerr.Conflict)
+	}
+	if !strings.Contains(rerr.Source, "registration_test.go:") || !strings.Contains(rerr.ConflictSource, "registration_test.go:") {
+		t.Errorf("sources should point here, got %q and %q", rerr.Source, rerr.ConflictSource)
+	}
+	if rerr.Source == rerr.ConflictSource {
+		t.Error("source and conflict source should differ")
+	}
+
+	err = mux.TryHandle("POST", "/users/:name", noop)
+	rerr, ok = err.(*kami.RegistrationError)
+	if !ok {
+		t.Fatalf("ambiguous wildcards: want *RegistrationError, got %T %v", err, err)
+	}
+	if rerr.Conflict != "GET /users/:id" {
+		t.Error("bad conflict:", rerr.Conflict)
+	}
+	if !strings.Contains(err.Error(), "POST /users/:name") {
+		t.Error("error should mention the route:", err)
+	}
+
+	err = mux.TryHandle("GET", "/bad", 42)
+	if rerr, ok := err.(*kami.RegistrationError); !ok || !strings.Contains(rerr.Reason, "unsupported HandlerType") {
+		t.Errorf("unsupported handler type: got %v", err)
+	}
+	if err := mux.TryHandle("GET", "no-slash", noop); err == nil {
+		t.Error("path without a leading slash should fail")
+	}
+
+	// failed registrations leave the router working
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/users/1", nil))
+	if resp.Code != http.StatusOK {
+		t.Error("want 200, got", resp.Code)
+	}
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("POST", "/users/1", nil))
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Error("want 405, got", resp.Code)
+	}
+
+	defer func() {
+		if _, ok := recover().(*kami.RegistrationError); !ok {
+			t.Error("Handle should panic with a *RegistrationError")
+		}
+	}()
+	mux.Get("/users/:id", noop)
+}
+
+func TestTryUse(t *testing.T) {
+	mux := kami.New()
+	mw := func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		return ctx
+	}
+
+	if err := mux.TryUse("/users/:id", mw); err != nil {
+		t.Fatal(err)
+	}
+	err := mux.TryUse("/", "not middleware")
+	if rerr, ok := err.(*kami.RegistrationError); !ok || !strings.Contains(rerr.Reason, "unsupported MiddlewareType") {
+		t.Errorf("unsupported middleware type: got %v", err)
+	}
+	if err := mux.TryAfter("/", 42); err == nil {
+		t.Error("unsupported afterware type should fail")
+	}
+
+	// differently named wildcards would share middleware
+	if err := mux.TryUse("/users/:id", mw); err != nil {
+		t.Error("same wildcards should be fine:", err)
+	}
+	for _, path := range []string{"/users/:name", "/users/*rest"} {
+		err = mux.TryUse(path, mw)
+		rerr, ok := err.(*kami.RegistrationError)
+		if !ok {
+			t.Errorf("%s: want *RegistrationError, got %T %v", path, err, err)
+			continue
+		}
+		if rerr.Conflict != "/users/:id" || !strings.Contains(rerr.ConflictSource, "registration_test.go:") {
+			t.Errorf("%s: bad conflict: %q (%s)", path, rerr.Conflict, rerr.ConflictSource)
+		}
+	}
+	aw := func(ctx context.Context, w mutil.WriterProxy, r *http.Request) context.Context {
+		return ctx
+	}
+	if err := mux.TryAfter("/files/*path", aw); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.TryAfter("/files/:name", aw); err == nil || !strings.Contains(err.Error(), "conflicts with /files/*path") {
+		t.Error("want ambiguous afterware error, got", err)
+	}
+}
@@ -0,0 +1,120 @@
+package kami
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// RouterOptions changes how request paths are matched to routes,
+// and what happens when a path almost matches, such as "/users/" for the route "/users".
+// The zero value is kami's default behavior: such requests are redirected,
+// with 301 Moved Permanently for GET and 307 Temporary Redirect for other methods,
+// so that request bodies aren't lost.
+// 	m := kami.New(kami.RouterOptions{IgnoreTrailingSlash: true})
+type RouterOptions struct {
+	// RedirectCode is the status used for redirects: 301, 307, or 308.
+	// If zero, 301 is used for GET requests and 307 for everything else.
+	RedirectCode int
+	// RedirectMethodCodes overrides RedirectCode for particular methods.
+	RedirectMethodCodes map[string]int
+	// RedirectHandler, if set, is called instead of sending a redirect.
+	// It is given the URL the request would have been redirected to and the redirect status.
+	// For example, JSON APIs may prefer to respond with an error document.
+	RedirectHandler func(w http.ResponseWriter, r *http.Request, url string, code int)
+
+	// NoTrailingSlashRedirect disables redirects that add or remove a trailing slash.
+	// Requests with a different trailing slash than their route are not found.
+	NoTrailingSlashRedirect bool
+	// NoCleanPathRedirect disables redirects to the cleaned version of paths
+	// with elements like "//" or "/../".
+	NoCleanPathRedirect bool
+	// IgnoreTrailingSlash treats "/a" and "/a/" as the same route,
+	// calling its handler directly instead of redirecting.
+	// This also applies to paths that need cleaning.
+	IgnoreTrailingSlash bool
+
+	// RawPath routes on the escaped path sent by the client instead of the decoded URL.Path,
+	// so that an escaped slash ("%2F") in a path parameter doesn't split it.
+	// Parameters are still decoded.
+	RawPath bool
+}
+
+// SetRouterOptions changes how the global router matches paths. See RouterOptions.
+// It must be called before any routes are registered.
+func SetRouterOptions(options RouterOptions) {
+	table.setOptions(options)
+}
+
+// setOptions applies options to the underlying router.
+func (t *routeTable) setOptions(options RouterOptions) {
+	if len(t.routes) > 0 {
+		panic("kami: router options must be set before registering routes")
+	}
+	t.options = options
+
+	tree := t.tree
+	tree.RedirectTrailingSlash = !options.NoTrailingSlashRedirect
+	tree.RedirectCleanPath = !options.NoCleanPathRedirect
+	tree.PathSource = httptreemux.URLPath
+	if options.RawPath {
+		tree.PathSource = httptreemux.RequestURI
+	}
+
+	tree.RedirectMethodBehavior = make(map[string]httptreemux.RedirectBehavior)
+	switch {
+	case options.IgnoreTrailingSlash:
+		tree.RedirectBehavior = httptreemux.UseHandler
+		return
+	case options.RedirectCode == 0:
+		tree.RedirectBehavior = httptreemux.Redirect307
+		tree.RedirectMethodBehavior["GET"] = httptreemux.Redirect301
+	default:
+		tree.RedirectBehavior = redirectBehavior(options.RedirectCode)
+	}
+	for method, code := range options.RedirectMethodCodes {
+		tree.RedirectMethodBehavior[method] = redirectBehavior(code)
+	}
+}
+
+func redirectBehavior(code int) httptreemux.RedirectBehavior {
+	switch code {
+	case http.StatusMovedPermanently:
+		return httptreemux.Redirect301
+	case http.StatusTemporaryRedirect:
+		return httptreemux.Redirect307
+	case 308: // http.StatusPermanentRedirect
+		return httptreemux.Redirect308
+	}
+	panic(fmt.Sprintf("kami: unsupported redirect code %d", code))
+}
+
+// ServeHTTP routes a request, calling the custom redirect handler if there is one.
+func (t *routeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tree := t.current().tree
+	if t.options.RedirectHandler == nil {
+		tree.ServeHTTP(w, r)
+		return
+	}
+
+	lr, _ := tree.Lookup(w, r)
+	switch lr.StatusCode {
+	case http.StatusMovedPermanently, http.StatusTemporaryRedirect, 308:
+		// httptreemux doesn't tell us where it wants to go, so let it redirect into a recorder
+		rec := redirectRecorder{header: make(http.Header)}
+		tree.ServeLookupResult(rec, r, lr)
+		t.options.RedirectHandler(w, r, rec.header.Get("Location"), lr.StatusCode)
+		return
+	}
+	tree.ServeLookupResult(w, r, lr)
+}
+
+// redirectRecorder captures the headers of a redirect, discarding everything else.
+type redirectRecorder struct {
+	header http.Header
+}
+
+func (rec redirectRecorder) Header() http.Header         { return rec.header }
+func (rec redirectRecorder) Write(p []byte) (int, error) { return len(p), nil }
+func (rec redirectRecorder) WriteHeader(int)             {}
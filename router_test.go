@@ -0,0 +1,84 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestRouterOptions(t *testing.T) {
+	name := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, kami.Param(ctx, "name"))
+	}
+	serve := func(mux *kami.Mux, method, uri string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, uri, nil)
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		options  kami.RouterOptions
+		method   string
+		uri      string
+		code     int
+		location string
+		body     string
+	}{
+		{"default GET", kami.RouterOptions{}, "GET", "/files/a/", 301, "/files/a", ""},
+		{"default POST", kami.RouterOptions{}, "POST", "/files/a/", 307, "/files/a", ""},
+		{"redirect code", kami.RouterOptions{RedirectCode: 308}, "GET", "/files/a/", 308, "/files/a", ""},
+		{"method codes", kami.RouterOptions{
+			RedirectCode:        308,
+			RedirectMethodCodes: map[string]int{"POST": 307},
+		}, "POST", "/files/a/", 307, "/files/a", ""},
+		{"no trailing slash redirect", kami.RouterOptions{NoTrailingSlashRedirect: true}, "GET", "/files/a/", 404, "", ""},
+		{"ignore trailing slash", kami.RouterOptions{IgnoreTrailingSlash: true}, "POST", "/files/a/", 200, "", "a"},
+		{"clean path", kami.RouterOptions{}, "GET", "/files//a", 301, "/files/a", ""},
+		{"no clean path redirect", kami.RouterOptions{NoCleanPathRedirect: true}, "GET", "/files//a", 404, "", ""},
+		{"decoded path", kami.RouterOptions{}, "GET", "/files/a%2Fb", 404, "", ""},
+		{"raw path", kami.RouterOptions{RawPath: true}, "GET", "/files/a%2Fb", 200, "", "a/b"},
+		{"redirect handler", kami.RouterOptions{
+			RedirectHandler: func(w http.ResponseWriter, r *http.Request, url string, code int) {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, url)
+			},
+		}, "POST", "/files/a/?x=1", 404, "", "/files/a?x=1"},
+	}
+
+	for _, test := range tests {
+		mux := kami.New(test.options)
+		mux.Get("/files/:name", name)
+		mux.Post("/files/:name", name)
+
+		resp := serve(mux, test.method, test.uri)
+		if resp.Code != test.code {
+			t.Errorf("%s: want code %d, got %d", test.name, test.code, resp.Code)
+		}
+		if loc := resp.Header().Get("Location"); loc != test.location {
+			t.Errorf("%s: want location %q, got %q", test.name, test.location, loc)
+		}
+		if test.body != "" && resp.Body.String() != test.body {
+			t.Errorf("%s: want body %q, got %q", test.name, test.body, resp.Body.String())
+		}
+	}
+}
+
+func TestRouterOptionsAfterRoutes(t *testing.T) {
+	defer kami.Reset()
+	kami.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SetRouterOptions after registering routes should panic")
+		}
+	}()
+	kami.SetRouterOptions(kami.RouterOptions{RawPath: true})
+}
@@ -0,0 +1,385 @@
+package kami
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// routeTable keeps track of registered routes on top of httptreemux.
+// Each method and path is given a single httptreemux handler,
+// which picks between handlers registered with different Matchers.
+//
+// Routes registered with handle go straight into the current tree, which isn't threadsafe.
+// Changes made with update build a new tree that replaces the current one atomically.
+type routeTable struct {
+	tree    *httptreemux.TreeMux // current tree, for registering routes and settings
+	routes  map[string]*route    // by method + " " + path
+	methods []string             // every method with a route
+	live    atomic.Value         // *routeSnapshot, for serving requests
+	mu      sync.Mutex           // held during updates
+
+	notAcceptable        httptreemux.HandlerFunc
+	unsupportedMediaType httptreemux.HandlerFunc
+	notFound             ContextHandler // the NotFound handler, before blessing
+
+	options RouterOptions
+}
+
+// routeSnapshot is the state of a routeTable needed to serve requests.
+type routeSnapshot struct {
+	tree    *httptreemux.TreeMux
+	methods []string
+}
+
+// route is a registered method and path.
+type route struct {
+	method, path string
+	handlers     []routeHandler // handlers with matchers first
+}
+
+type routeHandler struct {
+	matchers []Matcher
+	handler  httptreemux.HandlerFunc
+	source   string // where it was registered
+	info     *Route
+}
+
+func newRouteTable() *routeTable {
+	t := &routeTable{
+		tree:   newRouter(),
+		routes: make(map[string]*route),
+	}
+	t.publish()
+	return t
+}
+
+// current returns the routes to serve requests with.
+func (t *routeTable) current() *routeSnapshot {
+	return t.live.Load().(*routeSnapshot)
+}
+
+// publish makes the current tree visible to requests.
+func (t *routeTable) publish() {
+	t.live.Store(&routeSnapshot{tree: t.tree, methods: t.methods})
+}
+
+// handle registers a handler for the given method and path.
+// The handler is blessed by the given function.
+func (t *routeTable) handle(method, path string, handler HandlerType, bless blessFunc, matchers []Matcher) error {
+	rh, err := newRouteHandler(method, path, handler, bless, matchers)
+	if err != nil {
+		return err
+	}
+
+	key := method + " " + path
+	if rt, ok := t.routes[key]; ok {
+		return rt.add(rh)
+	}
+	rt := &route{method: method, path: path, handlers: []routeHandler{rh}}
+	if err := t.insert(t.tree, rt, t.routes); err != nil {
+		// the tree might be left half-modified, so start over without this route
+		t.rebuild(t.routes)
+		return err
+	}
+	t.routes[key] = rt
+	if !containsString(t.methods, method) {
+		t.methods = append(t.methods, method)
+		t.publish()
+	}
+	return nil
+}
+
+// insert adds a route to a tree, returning httptreemux's panics as errors.
+// The other routes in the tree are used to find what it conflicts with.
+func (t *routeTable) insert(tree *httptreemux.TreeMux, rt *route, routes map[string]*route) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			rerr := &RegistrationError{Method: rt.method, Path: rt.path, Source: rt.source(), Reason: fmt.Sprint(v)}
+			if conflict := findConflict(routes, rt); conflict != nil {
+				rerr.Conflict = conflict.method + " " + conflict.path
+				rerr.ConflictSource = conflict.source()
+			}
+			err = rerr
+		}
+	}()
+	tree.Handle(rt.method, rt.path, t.serve(rt))
+	return nil
+}
+
+// rebuild replaces the tree with a new one containing routes.
+func (t *routeTable) rebuild(routes map[string]*route) error {
+	tree, methods, err := t.build(routes)
+	if err != nil {
+		return err
+	}
+	t.install(tree, routes, methods)
+	return nil
+}
+
+// build makes a new tree containing routes, with the current tree's settings.
+func (t *routeTable) build(routes map[string]*route) (*httptreemux.TreeMux, []string, error) {
+	tree := newRouter()
+	copyTreeSettings(tree, t.tree)
+	var methods []string
+	for _, rt := range routes {
+		if err := t.insert(tree, rt, routes); err != nil {
+			return nil, nil, err
+		}
+		if !containsString(methods, rt.method) {
+			methods = append(methods, rt.method)
+		}
+	}
+	return tree, methods, nil
+}
+
+// install replaces the current tree with one made by build.
+func (t *routeTable) install(tree *httptreemux.TreeMux, routes map[string]*route, methods []string) {
+	t.tree = tree
+	t.routes = routes
+	t.methods = methods
+	t.publish()
+}
+
+// add adds a handler to this route.
+func (rt *route) add(rh routeHandler) error {
+	if len(rh.matchers) == 0 {
+		if n := len(rt.handlers); n > 0 && len(rt.handlers[n-1].matchers) == 0 {
+			return &RegistrationError{
+				Method:         rt.method,
+				Path:           rt.path,
+				Source:         rh.source,
+				Reason:         "a handler without matchers is already registered",
+				Conflict:       rt.method + " " + rt.path,
+				ConflictSource: rt.handlers[n-1].source,
+			}
+		}
+		rt.handlers = append(rt.handlers, rh)
+		return nil
+	}
+	// keep the fallback handler without matchers last
+	i := len(rt.handlers)
+	if i > 0 && len(rt.handlers[i-1].matchers) == 0 {
+		i--
+	}
+	rt.handlers = append(rt.handlers, routeHandler{})
+	copy(rt.handlers[i+1:], rt.handlers[i:])
+	rt.handlers[i] = rh
+	return nil
+}
+
+// source returns where the route's latest handler was registered.
+func (rt *route) source() string {
+	if len(rt.handlers) == 0 {
+		return ""
+	}
+	return rt.handlers[len(rt.handlers)-1].source
+}
+
+// serve returns the httptreemux handler for a route.
+func (t *routeTable) serve(rt *route) httptreemux.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if len(rt.handlers) == 1 && len(rt.handlers[0].matchers) == 0 {
+			rt.handlers[0].handler(w, r, params)
+			return
+		}
+
+		status := http.StatusNotFound
+		for _, rh := range rt.handlers {
+			ok, failed := match(rh.matchers, r)
+			if ok {
+				rh.handler(w, r, params)
+				return
+			}
+			if matchRank(failed) > matchRank(status) {
+				status = failed
+			}
+		}
+
+		switch status {
+		case http.StatusNotAcceptable:
+			t.notAcceptable(w, r, params)
+		case http.StatusUnsupportedMediaType:
+			t.unsupportedMediaType(w, r, params)
+		default:
+			t.current().tree.NotFoundHandler(w, r)
+		}
+	}
+}
+
+// Matcher is an extra condition a request must meet for a route's handler to run,
+// in addition to the method and path.
+// Give matchers to Handle, Get, etc. to register several handlers under the same method and path:
+//
+//	kami.Get("/users/:id", showUserV2, kami.Header("API-Version", "2"))
+//	kami.Get("/users/:id", showUserV1) // no matchers: used when nothing else matches
+//
+// When no handler matches a request, the response depends on why:
+// 406 Not Acceptable for Accepts, 415 Unsupported Media Type for ContentType, and 404 Not Found otherwise.
+// See NotAcceptable and UnsupportedMediaType to customize these responses.
+type Matcher struct {
+	match func(*http.Request) bool
+	// status is the response status when the request doesn't match.
+	status int
+}
+
+// Accepts matches requests whose Accept header allows any of the given media types.
+// Requests without an Accept header accept anything.
+func Accepts(types ...string) Matcher {
+	return Matcher{
+		match: func(r *http.Request) bool {
+			accept := r.Header.Get("Accept")
+			if accept == "" {
+				return true
+			}
+			for _, t := range types {
+				if accepts(accept, t) {
+					return true
+				}
+			}
+			return false
+		},
+		status: http.StatusNotAcceptable,
+	}
+}
+
+// ContentType matches requests whose Content-Type is any of the given media types.
+// Media types like "text/*" match every subtype.
+func ContentType(types ...string) Matcher {
+	return Matcher{
+		match: func(r *http.Request) bool {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil {
+				return false
+			}
+			for _, t := range types {
+				if mediaTypeMatches(strings.ToLower(t), mediaType) {
+					return true
+				}
+			}
+			return false
+		},
+		status: http.StatusUnsupportedMediaType,
+	}
+}
+
+// Header matches requests with a header of the given value, such as a custom API-Version header.
+// If value is blank, any request with the header matches.
+func Header(name, value string) Matcher {
+	return Matcher{
+		match: func(r *http.Request) bool {
+			values, ok := r.Header[http.CanonicalHeaderKey(name)]
+			if !ok {
+				return false
+			}
+			if value == "" {
+				return true
+			}
+			for _, v := range values {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		},
+		status: http.StatusNotFound,
+	}
+}
+
+// MatchFunc matches requests for which f returns true.
+func MatchFunc(f func(*http.Request) bool) Matcher {
+	return Matcher{match: f, status: http.StatusNotFound}
+}
+
+// match checks every matcher, returning the status of the first one that failed.
+func match(matchers []Matcher, r *http.Request) (ok bool, status int) {
+	for _, m := range matchers {
+		if !m.match(r) {
+			return false, m.status
+		}
+	}
+	return true, 0
+}
+
+// matchRank ranks statuses by how close the request came to matching.
+// Matchers are checked in the same order, so a request that fails on Accept
+// has passed everything else.
+func matchRank(status int) int {
+	switch status {
+	case http.StatusUnsupportedMediaType:
+		return 1
+	case http.StatusNotAcceptable:
+		return 2
+	}
+	return 0
+}
+
+// sortMatchers orders matchers by rank, keeping their order otherwise.
+func sortMatchers(matchers []Matcher) []Matcher {
+	sorted := make([]Matcher, 0, len(matchers))
+	for rank := 0; rank <= 2; rank++ {
+		for _, m := range matchers {
+			if matchRank(m.status) == rank {
+				sorted = append(sorted, m)
+			}
+		}
+	}
+	return sorted
+}
+
+// accepts returns true if an Accept header allows the given media type.
+func accepts(accept, mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		pattern := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaTypeMatches(pattern, mediaType) && !qZero(params[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// qZero returns true if the parameters of an Accept header element include q=0,
+// which rejects it.
+func qZero(params []string) bool {
+	for _, p := range params {
+		p = strings.TrimSpace(p)
+		if q := strings.TrimPrefix(p, "q="); q != p && strings.Trim(q, "0.") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypeMatches matches a media type against a pattern like "text/html", "text/*", or "*/*".
+func mediaTypeMatches(pattern, mediaType string) bool {
+	if pattern == mediaType || pattern == "*/*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, pattern[:len(pattern)-1])
+	}
+	return false
+}
+
+// errorHandler returns a handler that responds with a plain error for the given status.
+func errorHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(status), status)
+	})
+}
+
+func containsString(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestMatchers(t *testing.T) {
+	mux := kami.New()
+	text := func(s string) kami.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, s)
+		}
+	}
+	mux.Get("/users/:id", text("v2"), kami.Header("API-Version", "2"))
+	mux.Get("/users/:id", text("v1"))
+	mux.Get("/users/:id", text("v3"), kami.Header("API-Version", "3"))
+	mux.Get("/report", text("csv"), kami.Accepts("text/csv"))
+	mux.Get("/report", text("json"), kami.Accepts("application/json"))
+	mux.Post("/upload", text("json"), kami.ContentType("application/json"))
+	mux.Post("/upload", text("image"), kami.ContentType("image/*"))
+	mux.NotAcceptable(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		io.WriteString(w, "try csv or json")
+	})
+
+	expect := func(method, path string, header http.Header, code int, body string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header = header
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code {
+			t.Errorf("%s %s %v: want code %d, got %d", method, path, header, code, resp.Code)
+		}
+		if body != "" && resp.Body.String() != body {
+			t.Errorf("%s %s %v: want body %q, got %q", method, path, header, body, resp.Body.String())
+		}
+	}
+
+	expect("GET", "/users/1", http.Header{}, 200, "v1")
+	expect("GET", "/users/1", http.Header{"Api-Version": {"2"}}, 200, "v2")
+	expect("GET", "/users/1", http.Header{"Api-Version": {"3"}}, 200, "v3")
+	expect("GET", "/users/1", http.Header{"Api-Version": {"9"}}, 200, "v1")
+
+	expect("GET", "/report", http.Header{}, 200, "csv")
+	expect("GET", "/report", http.Header{"Accept": {"application/json"}}, 200, "json")
+	expect("GET", "/report", http.Header{"Accept": {"text/*;q=0.5, application/json;q=0"}}, 200, "csv")
+	expect("GET", "/report", http.Header{"Accept": {"text/html"}}, 406, "try csv or json")
+
+	expect("POST", "/upload", http.Header{"Content-Type": {"application/json; charset=utf-8"}}, 200, "json")
+	expect("POST", "/upload", http.Header{"Content-Type": {"image/png"}}, 200, "image")
+	expect("POST", "/upload", http.Header{"Content-Type": {"text/plain"}}, 415, "")
+	expect("POST", "/upload", http.Header{}, 415, "")
+}
+
+func TestMatchersDuplicate(t *testing.T) {
+	mux := kami.New()
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}
+	mux.Get("/", h, kami.Header("X-Test", ""))
+	mux.Get("/", h)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering two handlers without matchers should panic")
+		}
+	}()
+	mux.Get("/", h)
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/zenazn/goji/bind"
@@ -24,26 +25,26 @@ func init() {
 // by setting the "bind" command line flag.
 // Serve detects einhorn and systemd for you.
 // It works exactly like zenazn/goji.
-func Serve() {
+func Serve(opts ...ServeOption) {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 
-	serveListener(Handler(), bind.Default())
+	serveListener(Handler(), bind.Default(), opts)
 }
 
 // ServeTLS is like Serve, but enables TLS using the given config.
-func ServeTLS(config *tls.Config) {
+func ServeTLS(config *tls.Config, opts ...ServeOption) {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 
-	serveListener(Handler(), tls.NewListener(bind.Default(), config))
+	serveListener(Handler(), tls.NewListener(bind.Default(), config), opts)
 }
 
 // ServeListener is like Serve, but runs kami on top of an arbitrary net.Listener.
-func ServeListener(listener net.Listener) {
-	serveListener(Handler(), listener)
+func ServeListener(listener net.Listener, opts ...ServeOption) {
+	serveListener(Handler(), listener, opts)
 }
 
 // Serve starts serving this mux with reasonable defaults.
@@ -51,46 +52,123 @@ func ServeListener(listener net.Listener) {
 // by setting the "--bind" command line flag.
 // Serve detects einhorn and systemd for you.
 // It works exactly like zenazn/goji. Only one mux may be served at a time.
-func (m *Mux) Serve() {
+func (m *Mux) Serve(opts ...ServeOption) {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 
-	serveListener(m, bind.Default())
+	serveListener(m, bind.Default(), opts)
 }
 
 // ServeTLS is like Serve, but enables TLS using the given config.
-func (m *Mux) ServeTLS(config *tls.Config) {
+func (m *Mux) ServeTLS(config *tls.Config, opts ...ServeOption) {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 
-	serveListener(m, tls.NewListener(bind.Default(), config))
+	serveListener(m, tls.NewListener(bind.Default(), config), opts)
 }
 
 // ServeListener is like Serve, but runs kami on top of an arbitrary net.Listener.
-func (m *Mux) ServeListener(listener net.Listener) {
-	serveListener(m, listener)
+func (m *Mux) ServeListener(listener net.Listener, opts ...ServeOption) {
+	serveListener(m, listener, opts)
 }
 
-// ServeListener is like Serve, but runs kami on top of an arbitrary net.Listener.
-func serveListener(h http.Handler, listener net.Listener) {
+// ServeOption changes the way Serve, ServeTLS, and ServeListener run.
+type ServeOption func(*serveConfig)
+
+// serveConfig is the result of applying every ServeOption.
+type serveConfig struct {
+	// wrap holds functions that wrap the root handler, in order.
+	// Options that need to see connections before the default mux does use this.
+	wrap []func(http.Handler) http.Handler
+}
+
+func newServeConfig(opts []ServeOption) *serveConfig {
+	cfg := new(serveConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ServeListeners serves multiple handlers at once, each on its own listener, with graceful shutdown.
+// Unlike the other Serve functions, the handlers are not installed in http.DefaultServeMux.
+// Listeners start in order of their addresses, so the log is the same every run.
+// This can be used to serve a public mux and a private admin mux from a single process:
+// 	kami.ServeListeners(map[net.Listener]http.Handler{
+// 		publicListener: kami.Handler(),
+// 		adminListener:  adminMux,
+// 	})
+func ServeListeners(handlers map[net.Listener]http.Handler, opts ...ServeOption) {
+	serveListeners(handlers, opts)
+}
+
+// serveListener is like Serve, but runs kami on top of an arbitrary net.Listener.
+func serveListener(h http.Handler, listener net.Listener, opts []ServeOption) {
 	// Install our handler at the root of the standard net/http default mux.
 	// This allows packages like expvar to continue working as expected.
 	http.Handle("/", h)
 
-	log.Println("Starting kami on", listener.Addr())
+	serveListeners(map[net.Listener]http.Handler{listener: http.DefaultServeMux}, opts)
+}
 
-	graceful.HandleSignals()
-	bind.Ready()
-	graceful.PreHook(func() { log.Printf("kami received signal, gracefully stopping") })
-	graceful.PostHook(func() { log.Printf("kami stopped") })
+func serveListeners(handlers map[net.Listener]http.Handler, opts []ServeOption) {
+	cfg := newServeConfig(opts)
 
-	err := graceful.Serve(listener, http.DefaultServeMux)
+	// start in a stable order, so logs and hooks don't shuffle between runs
+	listeners := make([]net.Listener, 0, len(handlers))
+	for listener := range handlers {
+		listeners = append(listeners, listener)
+	}
+	sort.Sort(byAddr(listeners))
+	for _, listener := range listeners {
+		log.Println("Starting kami on", listener.Addr())
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	graceful.HandleSignals()
+	for _, hook := range startHooks {
+		hook()
+	}
+	bind.Ready()
+	graceful.PreHook(func() {
+		log.Printf("kami received signal, gracefully stopping")
+		setShuttingDown()
+	})
+
+	// wrap before adding our post hook, so that options' hooks run first
+	wrapped := make([]http.Handler, len(listeners))
+	for i, listener := range listeners {
+		h := handlers[listener]
+		for _, wrap := range cfg.wrap {
+			h = wrap(h)
+		}
+		wrapped[i] = h
+	}
+	graceful.PostHook(func() {
+		for _, hook := range shutdownHooks {
+			hook()
+		}
+		log.Printf("kami stopped")
+	})
+
+	errs := make(chan error, len(handlers))
+	for i, listener := range listeners {
+		go func(listener net.Listener, h http.Handler) {
+			errs <- serveGraceful(listener, h)
+		}(listener, wrapped[i])
+	}
+	for range listeners {
+		if err := <-errs; err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	graceful.Wait()
 }
+
+type byAddr []net.Listener
+
+func (ls byAddr) Len() int           { return len(ls) }
+func (ls byAddr) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
+func (ls byAddr) Less(i, j int) bool { return ls[i].Addr().String() < ls[j].Addr().String() }
@@ -0,0 +1,15 @@
+// +build !appengine,!go1.13
+
+package kami
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/zenazn/goji/graceful"
+)
+
+// serveGraceful serves h on l with graceful shutdown.
+func serveGraceful(l net.Listener, h http.Handler) error {
+	return graceful.Serve(l, h)
+}
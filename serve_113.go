@@ -0,0 +1,16 @@
+// +build !appengine,go1.13
+
+package kami
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/zenazn/goji/graceful"
+)
+
+// serveGraceful serves h on l with graceful shutdown.
+func serveGraceful(l net.Listener, h http.Handler) error {
+	srv := &graceful.Server{Handler: h, ConnContext: ProxyConnContext}
+	return srv.Serve(l)
+}
@@ -0,0 +1,81 @@
+// +build !appengine,go1.8
+
+package kami
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/zenazn/goji/graceful"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// H2C is a ServeOption that accepts HTTP/2 over cleartext TCP, in addition to HTTP/1.
+// Both prior knowledge connections and HTTP/1 connections upgraded with "Upgrade: h2c" are supported.
+// This is useful when kami sits behind a load balancer that speaks h2c to its backends.
+// Graceful shutdown sends GOAWAY to HTTP/2 connections and waits for them to finish.
+// HTTP/2 requests run through kami with a normal WriterProxy, so LogHandler works as usual.
+func H2C() ServeOption {
+	return func(cfg *serveConfig) {
+		cfg.wrap = append(cfg.wrap, newH2CHandler)
+	}
+}
+
+// h2cHandler keeps track of HTTP/2 cleartext connections for graceful shutdown.
+// The h2c package hijacks connections from the HTTP/1 server,
+// so the graceful package no longer knows about them.
+type h2cHandler struct {
+	// base never serves HTTP/1. It only exists so that http2.ConfigureServer
+	// gives us a way to send GOAWAY to every open connection.
+	base    *http.Server
+	handler http.Handler
+	conns   sync.WaitGroup
+}
+
+func newH2CHandler(h http.Handler) http.Handler {
+	h2s := new(http2.Server)
+	hh := &h2cHandler{
+		base:    new(http.Server),
+		handler: h2c.NewHandler(h, h2s),
+	}
+	if err := http2.ConfigureServer(hh.base, h2s); err != nil {
+		panic(err)
+	}
+	graceful.PreHook(hh.shutdown)
+	graceful.PostHook(hh.conns.Wait)
+	return hh
+}
+
+func (hh *h2cHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isH2C(r) {
+		hh.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// h2c serves the whole connection before returning.
+	hh.conns.Add(1)
+	defer hh.conns.Done()
+	// Hide the graceful server, whose ConnState hook complains about connections it has disowned.
+	// Without a server to copy its settings from, the connection is served
+	// by the http2.Server configured with base, so base.Shutdown reaches it.
+	r = r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, (*http.Server)(nil)))
+	hh.handler.ServeHTTP(w, r)
+}
+
+// shutdown asks every HTTP/2 connection to finish up.
+// The post hook waits for them, so that HTTP/1 listeners close in the meantime.
+func (hh *h2cHandler) shutdown() {
+	go hh.base.Shutdown(context.Background())
+}
+
+// isH2C returns true if r will be taken over by the h2c handler.
+func isH2C(r *http.Request) bool {
+	if r.Method == "PRI" && r.URL.Path == "*" && r.ProtoMajor == 2 {
+		return true
+	}
+	return httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "h2c") &&
+		httpguts.HeaderValuesContainsToken(r.Header["Connection"], "HTTP2-Settings")
+}
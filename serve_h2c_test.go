@@ -0,0 +1,182 @@
+// +build !appengine,go1.8
+
+package kami_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenazn/goji/graceful"
+	"github.com/zenazn/goji/web/mutil"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/guregu/kami"
+)
+
+// servedH2C is set once TestH2C has run. It serves for real, which claims http.DefaultServeMux
+// and shuts down the graceful package for the rest of the process.
+var servedH2C bool
+
+func TestH2C(t *testing.T) {
+	if servedH2C {
+		t.Skip("can only serve once per process")
+	}
+	servedH2C = true
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var logged []int
+	mux := kami.New()
+	mux.LogHandler = func(ctx context.Context, w mutil.WriterProxy, r *http.Request) {
+		mu.Lock()
+		logged = append(logged, w.Status())
+		mu.Unlock()
+	}
+	mux.Get("/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, r.Proto)
+	})
+	mux.Get("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	lastLogged := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(logged) == 0 {
+			return 0
+		}
+		return logged[len(logged)-1]
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	served := make(chan struct{})
+	go func() {
+		mux.ServeListener(l, kami.H2C())
+		close(served)
+	}()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	// prior knowledge
+	resp, err := client.Get("http://" + addr + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != "HTTP/2.0" {
+		t.Error("prior knowledge: unexpected response:", resp.StatusCode, string(body))
+	}
+	if status := lastLogged(); status != http.StatusCreated {
+		t.Error("prior knowledge: LogHandler saw status", status)
+	}
+
+	// upgrade from HTTP/1
+	if status := h2cUpgrade(t, addr, "/hello"); status != "201" {
+		t.Error("upgrade: unexpected status:", status)
+	}
+	if status := lastLogged(); status != http.StatusCreated {
+		t.Error("upgrade: LogHandler saw status", status)
+	}
+
+	// graceful shutdown waits for open HTTP/2 connections
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://" + addr + "/slow")
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "done" {
+				t.Error("slow: unexpected body:", string(body))
+			}
+		}
+		slow <- err
+	}()
+	<-started
+	go graceful.Shutdown()
+	select {
+	case <-served:
+		t.Fatal("shut down before the HTTP/2 connection finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Error("slow:", err)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Error("didn't shut down")
+	}
+}
+
+// h2cUpgrade makes a request with "Upgrade: h2c" and returns the HTTP/2 response's status.
+func h2cUpgrade(t *testing.T, addr, path string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+		"Host: "+addr+"\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\n"+
+		"HTTP2-Settings: \r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("upgrade refused:", resp.Status)
+	}
+
+	// the response to the upgraded request comes on stream 1
+	io.WriteString(conn, http2.ClientPreface)
+	framer := http2.NewFramer(conn, br)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				framer.WriteSettingsAck()
+			}
+		case *http2.MetaHeadersFrame:
+			if f.StreamID == 1 {
+				return f.PseudoValue("status")
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// +build !appengine
+
+package kami
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+)
+
+// ServeUnix is like Serve, but listens on a unix domain socket at the given path.
+// A stale socket left behind by a previous process is removed first,
+// and the new socket's permissions are set to mode.
+func ServeUnix(path string, mode os.FileMode, opts ...ServeOption) {
+	serveListener(Handler(), mustListenUnix(path, mode), opts)
+}
+
+// ServeUnix is like Serve, but listens on a unix domain socket at the given path.
+// A stale socket left behind by a previous process is removed first,
+// and the new socket's permissions are set to mode.
+func (m *Mux) ServeUnix(path string, mode os.FileMode, opts ...ServeOption) {
+	serveListener(m, mustListenUnix(path, mode), opts)
+}
+
+// ListenUnix listens on a unix domain socket at the given path, with its permissions set to mode.
+// If a socket already exists at path but nothing is listening on it, it is removed.
+// An error is returned if another process is still listening, or if path is not a socket.
+// The socket is removed when the listener is closed.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func mustListenUnix(path string, mode os.FileMode) net.Listener {
+	l, err := ListenUnix(path, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return l
+}
+
+// removeStaleSocket removes the socket at path if nobody is listening to it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("kami: %s exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("kami: %s is already in use", path)
+	}
+	return os.Remove(path)
+}
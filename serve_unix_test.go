@@ -0,0 +1,61 @@
+// +build !appengine,!windows
+
+package kami_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kami-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// leave a stale socket behind
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := kami.ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatal("stale socket should be removed:", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0660 {
+		t.Errorf("bad socket permissions: %v", perm)
+	}
+
+	// can't steal a socket in use
+	if _, err := kami.ListenUnix(path, 0660); err == nil {
+		t.Error("expected error listening on a socket in use")
+	}
+
+	l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("socket should be removed on close")
+	}
+
+	// refuse to delete regular files
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kami.ListenUnix(file, 0660); err == nil {
+		t.Error("expected error listening on a regular file")
+	}
+}
@@ -0,0 +1,81 @@
+// +build !appengine,!windows
+
+package kami
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const systemdMinFd = 3
+
+var (
+	systemdOnce      sync.Once
+	systemdListeners map[string][]net.Listener
+	systemdErr       error
+)
+
+// SystemdListeners returns the sockets passed by systemd socket activation, keyed by name.
+// Names come from the FileDescriptorName= setting of each socket unit (LISTEN_FDNAMES).
+// Sockets without a name are listed under "unknown", like sd_listen_fds_with_names.
+// It returns an empty map if the process was not started by systemd.
+// The sockets are only opened once; later calls return the same listeners.
+//
+// Combine it with ServeListeners to bind different muxes to different sockets:
+// 	listeners, err := kami.SystemdListeners()
+// 	// ...
+// 	kami.ServeListeners(map[net.Listener]http.Handler{
+// 		listeners["public"][0]: kami.Handler(),
+// 		listeners["admin"][0]:  adminMux,
+// 	})
+func SystemdListeners() (map[string][]net.Listener, error) {
+	systemdOnce.Do(func() {
+		systemdListeners, systemdErr = listenSystemd()
+	})
+	return systemdListeners, systemdErr
+}
+
+func listenSystemd() (map[string][]net.Listener, error) {
+	return listenFds(os.Getenv, func(i int) uintptr { return uintptr(systemdMinFd + i) })
+}
+
+// listenFds opens the sockets described by systemd's environment variables.
+// fdOf returns the file descriptor of the i-th socket.
+func listenFds(getenv func(string) string, fdOf func(i int) uintptr) (map[string][]net.Listener, error) {
+	listeners := make(map[string][]net.Listener)
+
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return listeners, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil {
+		return listeners, fmt.Errorf("kami: invalid LISTEN_FDS: %v", err)
+	}
+
+	var names []string
+	if env := getenv("LISTEN_FDNAMES"); env != "" {
+		names = strings.Split(env, ":")
+	}
+
+	for i := 0; i < n; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		fd := fdOf(i)
+		f := os.NewFile(fd, name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return listeners, fmt.Errorf("kami: systemd socket %s (fd %d): %v", name, fd, err)
+		}
+		listeners[name] = append(listeners[name], l)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,144 @@
+// +build !appengine,!windows
+
+package kami
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenFds(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name    string
+		env     map[string]string
+		sockets int
+		devNull bool           // pass a file that isn't a socket
+		want    map[string]int // listeners per name
+		wantErr bool
+	}{
+		{
+			name: "not systemd",
+			env:  map[string]string{},
+			want: map[string]int{},
+		},
+		{
+			name: "another process",
+			env:  map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+			want: map[string]int{},
+		},
+		{
+			name:    "bad LISTEN_FDS",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "two"},
+			wantErr: true,
+		},
+		{
+			name:    "no names",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "2"},
+			sockets: 2,
+			want:    map[string]int{"unknown": 2},
+		},
+		{
+			name:    "names",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "3", "LISTEN_FDNAMES": "public:admin:public"},
+			sockets: 3,
+			want:    map[string]int{"public": 2, "admin": 1},
+		},
+		{
+			name:    "missing names",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "3", "LISTEN_FDNAMES": ":admin"},
+			sockets: 3,
+			want:    map[string]int{"unknown": 2, "admin": 1},
+		},
+		{
+			name:    "not a socket",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "1"},
+			devNull: true,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		func() {
+			var fds []uintptr
+			var addrs []string
+			for i := 0; i < test.sockets; i++ {
+				fd, addr := listenerFd(t)
+				fds = append(fds, fd)
+				addrs = append(addrs, addr)
+			}
+			if test.devNull {
+				f, err := os.Open(os.DevNull)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				fd, err := syscall.Dup(int(f.Fd()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				fds = append(fds, uintptr(fd))
+			}
+
+			listeners, err := listenFds(
+				func(key string) string { return test.env[key] },
+				func(i int) uintptr { return fds[i] },
+			)
+			for _, ls := range listeners {
+				for _, l := range ls {
+					defer l.Close()
+				}
+			}
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("%s: want error", test.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				return
+			}
+			if len(listeners) != len(test.want) {
+				t.Errorf("%s: want %v, got %v", test.name, test.want, listeners)
+			}
+			var got []string
+			for name, n := range test.want {
+				if len(listeners[name]) != n {
+					t.Errorf("%s: want %d %q listeners, got %d", test.name, n, name, len(listeners[name]))
+				}
+				for _, l := range listeners[name] {
+					got = append(got, l.Addr().String())
+				}
+			}
+			for _, addr := range addrs {
+				if !containsString(got, addr) {
+					t.Errorf("%s: socket %s is missing from %v", test.name, addr, got)
+				}
+			}
+		}()
+	}
+}
+
+// listenerFd returns a new file descriptor for a listening socket, as systemd would pass it.
+func listenerFd(t *testing.T) (uintptr, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// listenFds closes the fd it's given, so give it one of its own
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uintptr(fd), l.Addr().String()
+}
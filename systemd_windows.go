@@ -0,0 +1,12 @@
+// +build !appengine
+
+package kami
+
+import (
+	"net"
+)
+
+// SystemdListeners returns an empty map, because systemd isn't available on Windows.
+func SystemdListeners() (map[string][]net.Listener, error) {
+	return map[string][]net.Listener{}, nil
+}
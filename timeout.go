@@ -0,0 +1,233 @@
+// +build go1.7
+
+package kami
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Timeout sets a deadline for requests to the given path and every path under it, like Use.
+// The deadline covers middleware and the handler, and is set on their context.
+// If they haven't finished in time, TimeoutHandler responds instead and
+// anything they write afterwards is discarded, returning http.ErrHandlerTimeout.
+// Afterware and LogHandler run once the response is sent, and can check TimedOut.
+//
+// The most specific path's timeout is used, so a longer timeout can be given to part of a tree,
+// and a timeout of zero disables it. Wildcard paths are not supported.
+// See WithTimeout to set the timeout of a single route.
+func Timeout(path string, d time.Duration) {
+	defaultMW.Timeout(path, d)
+}
+
+// Timeout sets a deadline for requests to the given path and every path under it.
+// See the global Timeout function's documents for more information.
+func (m *wares) Timeout(path string, d time.Duration) {
+	if m.timeouts == nil {
+		m.timeouts = make(map[string]time.Duration)
+	}
+	m.timeouts[path] = d
+}
+
+// WithTimeout gives a route its own timeout, overriding timeouts set with Timeout.
+// A timeout of zero disables them.
+// 	kami.Post("/upload", kami.WithTimeout(upload, 5*time.Minute))
+func WithTimeout(handler HandlerType, d time.Duration) ContextHandler {
+	ro := withRouteOptions(handler)
+	ro.timeout = d
+	if d <= 0 {
+		ro.timeout = -1
+	}
+	return ro
+}
+
+// TimedOut returns true if this request's middleware or handler didn't finish before its timeout.
+// It is useful in afterware and LogHandler.
+func TimedOut(ctx context.Context) bool {
+	timedOut, _ := ctx.Value(timedOutKey{}).(bool)
+	return timedOut
+}
+
+type timedOutKey struct{}
+
+// timeout finds the timeout for this request's path, if one was registered.
+func (m *wares) timeout(r *http.Request) (d time.Duration, ok bool) {
+	if m.timeouts == nil {
+		return 0, false
+	}
+	path := r.URL.Path
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' || i == len(path)-1 {
+			if t, found := m.timeouts[path[:i+1]]; found {
+				d, ok = t, true
+			}
+		}
+	}
+	return d, ok
+}
+
+// timeoutFor returns the timeout for a request, or zero if it doesn't have one.
+func (k kami) timeoutFor(mw *wares, r *http.Request) time.Duration {
+	if k.timeout != 0 {
+		return k.timeout
+	}
+	var d time.Duration
+	if k.shared != nil {
+		d, _ = k.shared.timeout(r)
+	}
+	if own, ok := mw.timeout(r); ok {
+		d = own
+	}
+	return d
+}
+
+// runTimeout runs middleware and the handler until they finish or ctx is done.
+// If they don't finish in time, the timeout handler responds.
+func (k kami) runTimeout(ctx context.Context, w http.ResponseWriter, r *http.Request, mw *wares) (*http.Request, context.Context) {
+	tw := &timeoutWriter{w: w, header: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan *handlerPanic, 1)
+	var newR *http.Request
+	var newCtx context.Context
+	go func(ctx context.Context, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				p := &handlerPanic{value: v, stack: debug.Stack()}
+				// hold the lock so that the panic is either sent before the timeout or handled here
+				tw.mu.Lock()
+				late := tw.timedOut
+				if !late {
+					panicked <- p
+				}
+				tw.mu.Unlock()
+				if late {
+					k.latePanic(ctx, tw, r, p)
+				}
+				return
+			}
+			close(done)
+		}()
+		newR, newCtx = k.run(ctx, tw, r, mw)
+	}(ctx, r)
+
+	select {
+	case <-done:
+		return newR, newCtx
+	case p := <-panicked:
+		// let kami's panic handling take it from here
+		panic(p)
+	case <-ctx.Done():
+	}
+
+	tw.mu.Lock()
+	tw.timedOut = true
+	wrote := tw.wroteHeader
+	tw.mu.Unlock()
+	select {
+	case p := <-panicked:
+		// it panicked right as time ran out
+		panic(p)
+	default:
+	}
+
+	ctx = context.WithValue(ctx, timedOutKey{}, true)
+	r = r.WithContext(ctx)
+	if !wrote {
+		handler := *k.timeoutHandler
+		if handler == nil {
+			handler = errorHandler(http.StatusServiceUnavailable)
+		}
+		wrap(handler).ServeHTTPContext(ctx, w, r)
+	}
+	return r, ctx
+}
+
+// latePanic handles a panic from middleware or a handler that had already timed out.
+// PanicHandler is called as usual, but the response has been sent, so anything it writes is discarded.
+// Without a PanicHandler, the panic is logged.
+func (k kami) latePanic(ctx context.Context, w http.ResponseWriter, r *http.Request, p *handlerPanic) {
+	handler := *k.panicHandler
+	if handler == nil {
+		log.Printf("kami: panic serving %s %s after it timed out: %v\n%s", r.Method, r.URL.Path, p.value, p.stack)
+		return
+	}
+	ctx = context.WithValue(ctx, timedOutKey{}, true)
+	ctx = newContextWithException(ctx, p.value, p.stack)
+	wrap(handler).ServeHTTPContext(ctx, w, r.WithContext(ctx))
+}
+
+// handlerPanic is a panic recovered from runTimeout's goroutine,
+// re-raised in the request's goroutine with the stack trace of where it happened.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *handlerPanic) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// timeoutWriter lets the handler write until it times out.
+// Headers are kept separately until the handler writes them,
+// so that they don't mix with the timeout response.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+// writeHeader sends the handler's headers. tw.mu must be held.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,187 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/kami"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+func TestTimeout(t *testing.T) {
+	late := make(chan error, 1)
+	slow := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Slow", "yes")
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := io.WriteString(w, "too late")
+		late <- err
+	}
+	fast := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("no deadline on context")
+		}
+		io.WriteString(w, "ok")
+	}
+
+	var logged []int
+	var timedOut []bool
+	mux := kami.New()
+	mux.Timeout("/", 20*time.Millisecond)
+	mux.Timeout("/uploads/", time.Hour)
+	mux.Timeout("/forever/", 0)
+	mux.After("/", func(ctx context.Context, w mutil.WriterProxy, r *http.Request) context.Context {
+		timedOut = append(timedOut, kami.TimedOut(ctx))
+		return ctx
+	})
+	mux.LogHandler = func(ctx context.Context, w mutil.WriterProxy, r *http.Request) {
+		logged = append(logged, w.Status())
+	}
+	mux.Get("/slow", slow)
+	mux.Get("/fast", fast)
+	mux.Get("/uploads/fast", fast)
+	mux.Get("/forever/fast", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("want no deadline")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Get("/route", kami.WithTimeout(slow, 10*time.Millisecond))
+	mux.Get("/uploads/route", kami.WithTimeout(slow, 10*time.Millisecond))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		logged, timedOut = nil, nil
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+		return resp
+	}
+
+	resp := serve("/slow")
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Error("want 503, got", resp.Code)
+	}
+	if resp.Header().Get("X-Slow") != "" {
+		t.Error("handler's headers leaked into the timeout response")
+	}
+	if len(logged) != 1 || logged[0] != http.StatusServiceUnavailable {
+		t.Error("LogHandler didn't see the timeout:", logged)
+	}
+	if len(timedOut) != 1 || !timedOut[0] {
+		t.Error("afterware didn't see the timeout:", timedOut)
+	}
+	if err := <-late; err != http.ErrHandlerTimeout {
+		t.Error("want ErrHandlerTimeout for late write, got", err)
+	}
+
+	for _, path := range []string{"/fast", "/uploads/fast", "/forever/fast"} {
+		if resp := serve(path); resp.Code != http.StatusOK {
+			t.Errorf("%s: want 200, got %d", path, resp.Code)
+		}
+		if len(timedOut) != 1 || timedOut[0] {
+			t.Errorf("%s: want not timed out, got %v", path, timedOut)
+		}
+	}
+
+	// per-route timeouts override per-path ones
+	mux.TimeoutHandler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}
+	start := time.Now()
+	if resp := serve("/uploads/route"); resp.Code != http.StatusGatewayTimeout {
+		t.Error("want custom 504, got", resp.Code)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("route timeout didn't override path timeout")
+	}
+	<-late
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	var recovered interface{}
+	var stack []byte
+	mux := kami.New()
+	mux.Timeout("/", time.Second)
+	mux.PanicHandler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		recovered = kami.Exception(ctx)
+		stack = kami.PanicStack(ctx)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	mux.Get("/", panicky)
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	if resp.Code != http.StatusInternalServerError || recovered != "oops" {
+		t.Errorf("want panic handler, got %d %v", resp.Code, recovered)
+	}
+	if !strings.Contains(string(stack), "kami_test.panicky") {
+		t.Errorf("want the panicking goroutine's stack, got:\n%s", stack)
+	}
+}
+
+func TestTimeoutLatePanic(t *testing.T) {
+	late := make(chan interface{}, 1)
+	responded := make(chan struct{})
+	mux := kami.New()
+	mux.Timeout("/", 10*time.Millisecond)
+	mux.TimeoutHandler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		close(responded)
+	}
+	mux.PanicHandler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if !kami.TimedOut(ctx) {
+			t.Error("want timed out context")
+		}
+		if !strings.Contains(string(kami.PanicStack(ctx)), "kami_test.panicky") {
+			t.Errorf("want the panicking goroutine's stack, got:\n%s", kami.PanicStack(ctx))
+		}
+		late <- kami.Exception(ctx)
+	}
+	mux.Get("/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		<-responded
+		panicky(ctx, w, r)
+	})
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Error("want timeout, got", resp.Code)
+	}
+	select {
+	case v := <-late:
+		if v != "oops" {
+			t.Error("want oops, got", v)
+		}
+	case <-time.After(time.Second):
+		t.Error("late panic didn't reach the panic handler")
+	}
+}
+
+func TestTimeoutUnicodePath(t *testing.T) {
+	mux := kami.New()
+	mux.Timeout("/café", 10*time.Millisecond)
+	mux.Get("/café", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/caf%C3%A9", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Error("want timeout, got", resp.Code)
+	}
+}
+
+func panicky(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	panic("oops")
+}
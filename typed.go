@@ -0,0 +1,129 @@
+// +build go1.18
+
+package kami
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// HandlerFuncs is the set of function types that NewHandler accepts.
+// To use a value that implements http.Handler or ContextHandler, pass its method value,
+// such as h.ServeHTTP.
+type HandlerFuncs interface {
+	HandlerFunc |
+		func(context.Context, http.ResponseWriter, *http.Request) |
+		http.HandlerFunc |
+		func(http.ResponseWriter, *http.Request)
+}
+
+// MiddlewareFuncs is the set of function types that NewMiddleware accepts.
+// See MiddlewareType for how each is run.
+type MiddlewareFuncs interface {
+	Middleware |
+		func(context.Context, http.ResponseWriter, *http.Request) context.Context |
+		func(http.ResponseWriter, *http.Request) context.Context |
+		func(http.Handler) http.Handler |
+		func(ContextHandler) ContextHandler |
+		http.HandlerFunc |
+		func(http.ResponseWriter, *http.Request)
+}
+
+// AfterwareFuncs is the set of function types that NewAfterware accepts.
+// See AfterwareType for how each is run.
+type AfterwareFuncs interface {
+	Afterware |
+		func(context.Context, mutil.WriterProxy, *http.Request) context.Context |
+		func(context.Context, *http.Request) context.Context |
+		func(context.Context) context.Context |
+		Middleware |
+		func(context.Context, http.ResponseWriter, *http.Request) context.Context |
+		func(http.ResponseWriter, *http.Request) context.Context |
+		func(mutil.WriterProxy, *http.Request) context.Context |
+		http.HandlerFunc |
+		func(http.ResponseWriter, *http.Request) |
+		func(mutil.WriterProxy, *http.Request)
+}
+
+// NewHandler converts a handler function to a ContextHandler.
+// Unlike passing a HandlerType to Handle, Get, etc., functions with unsupported signatures
+// are rejected by the compiler instead of causing a panic at run time.
+// 	kami.Get("/users/:id", kami.NewHandler(showUser))
+func NewHandler[F HandlerFuncs](f F) ContextHandler {
+	return wrap(f)
+}
+
+// NewMiddleware converts a middleware function to Middleware.
+// Unlike passing a MiddlewareType to Use, functions with unsupported signatures
+// are rejected by the compiler instead of causing a panic at run time.
+// 	kami.Use("/", kami.NewMiddleware(loadUser))
+func NewMiddleware[F MiddlewareFuncs](f F) Middleware {
+	return convert(f)
+}
+
+// NewAfterware converts an afterware function to Afterware.
+// Unlike passing an AfterwareType to After, functions with unsupported signatures
+// are rejected by the compiler instead of causing a panic at run time.
+// 	kami.After("/", kami.NewAfterware(logRequest))
+func NewAfterware[F AfterwareFuncs](f F) Afterware {
+	return convertAW(f)
+}
+
+// JSON converts a function taking and returning JSON-encoded values to a ContextHandler.
+// The request body, if any, is decoded into Req; if it isn't valid JSON, the handler responds
+// with 400 Bad Request without calling f. The returned Resp is encoded as the response body.
+//
+// If f returns an error with a StatusCode() int method, its status code and message are sent.
+// Otherwise, errors respond with 500 Internal Server Error.
+// Errors are sent as JSON too, like {"error": "Bad Request"}.
+//
+// Routes with JSON handlers describe their request and response bodies in OpenAPI documents.
+// 	kami.Post("/users", kami.JSON(createUser))
+func JSON[Req, Resp any](f func(context.Context, Req) (Resp, error)) ContextHandler {
+	return jsonHandler[Req, Resp](f)
+}
+
+type jsonHandler[Req, Resp any] func(context.Context, Req) (Resp, error)
+
+func (f jsonHandler[Req, Resp]) ServeHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req Req
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			jsonError(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	resp, err := f(ctx, req)
+	if err != nil {
+		var coded interface{ StatusCode() int }
+		if errors.As(err, &coded) {
+			jsonError(w, err.Error(), coded.StatusCode())
+			return
+		}
+		jsonError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// jsonError responds with a JSON error message, like http.Error does with plain text.
+func jsonError(w http.ResponseWriter, msg string, code int) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
+
+func (jsonHandler[Req, Resp]) types() (request, response reflect.Type) {
+	return reflect.TypeOf((*Req)(nil)).Elem(), reflect.TypeOf((*Resp)(nil)).Elem()
+}
@@ -0,0 +1,64 @@
+// +build go1.18
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/guregu/kami"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+func TestTyped(t *testing.T) {
+	type key struct{}
+	var after []string
+
+	mux := kami.New()
+	mux.Use("/", kami.NewMiddleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+		return context.WithValue(ctx, key{}, "mw")
+	}))
+	mux.Use("/", kami.NewMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Std", "ok")
+			next.ServeHTTP(w, r)
+		})
+	}))
+	mux.After("/", kami.NewAfterware(func(ctx context.Context, w mutil.WriterProxy, r *http.Request) context.Context {
+		after = append(after, "ctx")
+		return ctx
+	}))
+	mux.After("/", kami.NewAfterware(func(w mutil.WriterProxy, r *http.Request) {
+		after = append(after, "std")
+	}))
+	mux.Get("/ctx", kami.NewHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, ctx.Value(key{}).(string))
+	}))
+	mux.Get("/std", kami.NewHandler(http.NotFound))
+	mux.Get("/method", kami.NewHandler(http.RedirectHandler("/", http.StatusFound).ServeHTTP))
+
+	expect := func(path string, code int, body string) {
+		t.Helper()
+		after = nil
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+		if resp.Code != code {
+			t.Errorf("%s: want code %d, got %d", path, code, resp.Code)
+		}
+		if body != "" && resp.Body.String() != body {
+			t.Errorf("%s: want body %q, got %q", path, body, resp.Body.String())
+		}
+		if resp.Header().Get("X-Std") != "ok" {
+			t.Errorf("%s: standard middleware didn't run", path)
+		}
+		if len(after) != 2 {
+			t.Errorf("%s: want 2 afterware, got %v", path, after)
+		}
+	}
+	expect("/ctx", 200, "mw")
+	expect("/std", 404, "")
+	expect("/method", 302, "")
+}
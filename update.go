@@ -0,0 +1,181 @@
+package kami
+
+import (
+	"github.com/dimfeld/httptreemux"
+)
+
+// RouteUpdate is a batch of route changes made with Update.
+// None of the changes are visible to requests until the update is finished,
+// and then they all are at once.
+type RouteUpdate struct {
+	bless   blessFunc
+	routes  map[string]*route
+	copied  map[*route]bool // routes that belong to this update, safe to modify
+	changed bool
+	err     error
+}
+
+// Handle registers an arbitrary method handler under the given path, like the Handle function.
+// If the handler can't be registered, the update fails and Update returns the error.
+func (u *RouteUpdate) Handle(method, path string, handler HandlerType, matchers ...Matcher) {
+	if u.err != nil {
+		return
+	}
+	rh, err := newRouteHandler(method, path, handler, u.bless, matchers)
+	if err == nil {
+		err = u.route(method, path).add(rh)
+	}
+	u.err = err
+}
+
+// Replace registers a handler under the given path, removing the method's existing handlers first,
+// including handlers registered with matchers.
+func (u *RouteUpdate) Replace(method, path string, handler HandlerType, matchers ...Matcher) {
+	u.Remove(method, path)
+	u.Handle(method, path, handler, matchers...)
+}
+
+// Remove unregisters every handler for the given method and path,
+// reporting whether there were any.
+func (u *RouteUpdate) Remove(method, path string) bool {
+	key := method + " " + path
+	if _, ok := u.routes[key]; !ok {
+		return false
+	}
+	delete(u.routes, key)
+	u.changed = true
+	return true
+}
+
+// route returns the route for a method and path that is safe to modify, creating it if needed.
+func (u *RouteUpdate) route(method, path string) *route {
+	u.changed = true
+	key := method + " " + path
+	rt, ok := u.routes[key]
+	switch {
+	case !ok:
+		rt = &route{method: method, path: path}
+	case !u.copied[rt]:
+		// requests may be using the original, so leave it alone
+		rt = &route{
+			method:   method,
+			path:     path,
+			handlers: append([]routeHandler(nil), rt.handlers...),
+		}
+	default:
+		return rt
+	}
+	u.routes[key] = rt
+	u.copied[rt] = true
+	return rt
+}
+
+// update calls f to make a batch of changes, then swaps in a new tree with those changes.
+// If any change fails, no changes are made.
+func (t *routeTable) update(bless blessFunc, f func(*RouteUpdate)) error {
+	return t.updateAfter(bless, f, nil)
+}
+
+// updateAfter is like update, but calls before once the new tree is built and before requests can see it.
+// If before returns an error, the update is abandoned.
+func (t *routeTable) updateAfter(bless blessFunc, f func(*RouteUpdate), before func() error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := &RouteUpdate{
+		bless:  bless,
+		routes: make(map[string]*route, len(t.routes)),
+		copied: make(map[*route]bool),
+	}
+	for key, rt := range t.routes {
+		u.routes[key] = rt
+	}
+	f(u)
+	if u.err != nil {
+		return u.err
+	}
+	if !u.changed {
+		if before != nil {
+			return before()
+		}
+		return nil
+	}
+	tree, methods, err := t.build(u.routes)
+	if err != nil {
+		return err
+	}
+	if before != nil {
+		if err := before(); err != nil {
+			return err
+		}
+	}
+	t.install(tree, u.routes, methods)
+	return nil
+}
+
+// copyTreeSettings copies the settings kami uses from one tree to another.
+func copyTreeSettings(dst, src *httptreemux.TreeMux) {
+	dst.NotFoundHandler = src.NotFoundHandler
+	dst.MethodNotAllowedHandler = src.MethodNotAllowedHandler
+	dst.OptionsHandler = src.OptionsHandler
+	dst.PanicHandler = src.PanicHandler
+	dst.HeadCanUseGet = src.HeadCanUseGet
+	dst.RedirectTrailingSlash = src.RedirectTrailingSlash
+	dst.RedirectCleanPath = src.RedirectCleanPath
+	dst.RedirectBehavior = src.RedirectBehavior
+	dst.RedirectMethodBehavior = src.RedirectMethodBehavior
+	dst.PathSource = src.PathSource
+}
+
+// Update makes a batch of route changes to the global router.
+// Unlike Handle, it is safe to call while serving requests.
+// Requests see either all of the changes or none of them.
+// If a change fails, such as a route that conflicts with another,
+// none of the changes are made and a *RegistrationError is returned.
+// 	err := kami.Update(func(u *kami.RouteUpdate) {
+// 		u.Remove("GET", "/beta")
+// 		u.Replace("GET", "/search", newSearch)
+// 	})
+func Update(f func(*RouteUpdate)) error {
+	return table.update(bless, f)
+}
+
+// Remove unregisters every handler for the given method and path from the global router,
+// reporting whether there were any. It is safe to call while serving requests.
+func Remove(method, path string) (removed bool) {
+	Update(func(u *RouteUpdate) {
+		removed = u.Remove(method, path)
+	})
+	return
+}
+
+// Replace registers a handler with the global router, replacing any existing handlers
+// for the method and path. It is safe to call while serving requests.
+func Replace(method, path string, handler HandlerType, matchers ...Matcher) error {
+	return Update(func(u *RouteUpdate) {
+		u.Replace(method, path, handler, matchers...)
+	})
+}
+
+// Update makes a batch of route changes. It is safe to call while serving requests.
+// See the global Update function's documents for more information.
+func (m *Mux) Update(f func(*RouteUpdate)) error {
+	return m.table.update(m.bless, f)
+}
+
+// Remove unregisters every handler for the given method and path,
+// reporting whether there were any. It is safe to call while serving requests.
+func (m *Mux) Remove(method, path string) (removed bool) {
+	m.Update(func(u *RouteUpdate) {
+		removed = u.Remove(method, path)
+	})
+	return
+}
+
+// Replace registers a handler, replacing any existing handlers for the method and path.
+// It is safe to call while serving requests.
+func (m *Mux) Replace(method, path string, handler HandlerType, matchers ...Matcher) error {
+	return m.Update(func(u *RouteUpdate) {
+		u.Replace(method, path, handler, matchers...)
+	})
+}
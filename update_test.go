@@ -0,0 +1,128 @@
+// +build go1.7
+
+package kami_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/guregu/kami"
+)
+
+func TestUpdate(t *testing.T) {
+	text := func(s string) kami.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, s)
+		}
+	}
+	mux := kami.New()
+	mux.EnableAutoOptions(true)
+	mux.NotFound(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "custom 404")
+	})
+	mux.Get("/a", text("a"))
+	mux.Get("/b", text("b"))
+	mux.Get("/b", text("b2"), kami.Header("Version", "2"))
+
+	expect := func(method, path string, code int, body string) {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Version", "2")
+		mux.ServeHTTP(resp, req)
+		if resp.Code != code || resp.Body.String() != body {
+			t.Errorf("%s %s: want %d %q, got %d %q", method, path, code, body, resp.Code, resp.Body.String())
+		}
+	}
+
+	if !mux.Remove("GET", "/a") {
+		t.Error("Remove should report that /a existed")
+	}
+	if mux.Remove("GET", "/a") {
+		t.Error("Remove should report that /a is gone")
+	}
+	expect("GET", "/a", 404, "custom 404")
+
+	if err := mux.Replace("GET", "/b", text("new b")); err != nil {
+		t.Fatal(err)
+	}
+	expect("GET", "/b", 200, "new b")
+
+	if err := mux.Update(func(u *kami.RouteUpdate) {
+		u.Handle("GET", "/c", text("c"))
+		u.Handle("POST", "/c", text("c2"), kami.Header("Version", "2"))
+		u.Handle("POST", "/c", text("c1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect("GET", "/c", 200, "c")
+	expect("POST", "/c", 200, "c2")
+	expect("PUT", "/c", 405, "Method Not Allowed\n")
+	expect("OPTIONS", "/c", 204, "")
+
+	// a failed update changes nothing
+	err := mux.Update(func(u *kami.RouteUpdate) {
+		u.Remove("GET", "/b")
+		u.Handle("GET", "/c", text("dupe"))
+	})
+	if err == nil {
+		t.Error("duplicate handler in update should fail")
+	}
+	err = mux.Update(func(u *kami.RouteUpdate) {
+		u.Remove("GET", "/b")
+		u.Handle("GET", "/c/:id", text("c"))
+		u.Handle("POST", "/c/:name", text("ambiguous"))
+	})
+	if err == nil {
+		t.Error("ambiguous wildcards in update should fail")
+	}
+	expect("GET", "/b", 200, "new b")
+	expect("GET", "/c", 200, "c")
+	expect("GET", "/c/1", 404, "custom 404")
+}
+
+func TestUpdateWhileServing(t *testing.T) {
+	mux := kami.New()
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.Get("/stable", ok)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				resp := httptest.NewRecorder()
+				mux.ServeHTTP(resp, httptest.NewRequest("GET", "/stable", nil))
+				if resp.Code != http.StatusOK {
+					t.Error("stable route should always be served, got", resp.Code)
+					return
+				}
+				mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/flag", nil))
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			mux.Replace("GET", "/flag", ok)
+		} else {
+			mux.Remove("GET", "/flag")
+		}
+	}
+	close(stop)
+	wg.Wait()
+}